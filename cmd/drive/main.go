@@ -24,6 +24,8 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/odeke-em/drive/config"
 	"github.com/odeke-em/drive/gen"
@@ -52,8 +54,13 @@ func main() {
 	runtime.GOMAXPROCS(int(maxProcs))
 
 	bindCommandWithAliases(drive.AboutKey, drive.DescAbout, &aboutCmd{}, []string{})
+	bindCommandWithAliases(drive.ApplyKey, drive.DescApply, &applyCmd{}, []string{})
+	bindCommandWithAliases(drive.CacheKey, drive.DescCache, &cacheCmd{}, []string{})
 	bindCommandWithAliases(drive.CopyKey, drive.DescCopy, &copyCmd{}, []string{})
+	bindCommandWithAliases(drive.DaemonKey, drive.DescDaemon, &daemonCmd{}, []string{})
+	bindCommandWithAliases(drive.DedupeKey, drive.DescDedupe, &dedupeCmd{}, []string{})
 	bindCommandWithAliases(drive.DiffKey, drive.DescDiff, &diffCmd{}, []string{})
+	bindCommandWithAliases(drive.DuKey, drive.DescDu, &duCmd{}, []string{})
 	bindCommandWithAliases(drive.EmptyTrashKey, drive.DescEmptyTrash, &emptyTrashCmd{}, []string{})
 	bindCommandWithAliases(drive.FeaturesKey, drive.DescFeatures, &featuresCmd{}, []string{})
 	bindCommandWithAliases(drive.InitKey, drive.DescInit, &initCmd{}, []string{})
@@ -65,16 +72,24 @@ func main() {
 	bindCommandWithAliases(drive.PushKey, drive.DescPush, &pushCmd{}, []string{})
 	bindCommandWithAliases(drive.PubKey, drive.DescPublish, &publishCmd{}, []string{})
 	bindCommandWithAliases(drive.RenameKey, drive.DescRename, &renameCmd{}, []string{})
+	bindCommandWithAliases(drive.RevisionsKey, drive.DescRevisions, &revisionsCmd{}, []string{})
 	bindCommandWithAliases(drive.QuotaKey, drive.DescQuota, &quotaCmd{}, []string{})
+	bindCommandWithAliases(drive.SearchKey, drive.DescSearch, &searchCmd{}, []string{})
 	bindCommandWithAliases(drive.ShareKey, drive.DescShare, &shareCmd{}, []string{})
+	bindCommandWithAliases(drive.SparseKey, drive.DescSparse, &sparseCmd{}, []string{})
 	bindCommandWithAliases(drive.StatKey, drive.DescStat, &statCmd{}, []string{})
+	bindCommandWithAliases(drive.StatusKey, drive.DescStatus, &statusCmd{}, []string{})
+	bindCommandWithAliases(drive.SyncKey, drive.DescSync, &syncCmd{}, []string{})
 	bindCommandWithAliases(drive.UnshareKey, drive.DescUnshare, &unshareCmd{}, []string{})
 	bindCommandWithAliases(drive.TouchKey, drive.DescTouch, &touchCmd{}, []string{})
 	bindCommandWithAliases(drive.TrashKey, drive.DescTrash, &trashCmd{}, []string{})
+	bindCommandWithAliases(drive.TreeKey, drive.DescTree, &treeCmd{}, []string{})
+	bindCommandWithAliases(drive.UndoKey, drive.DescUndo, &undoCmd{}, []string{})
 	bindCommandWithAliases(drive.UntrashKey, drive.DescUntrash, &untrashCmd{}, []string{})
 	bindCommandWithAliases(drive.DeleteKey, drive.DescDelete, &deleteCmd{}, []string{})
 	bindCommandWithAliases(drive.UnpubKey, drive.DescUnpublish, &unpublishCmd{}, []string{})
 	bindCommandWithAliases(drive.VersionKey, drive.Version, &versionCmd{}, []string{})
+	bindCommandWithAliases(drive.WatchKey, drive.DescWatch, &watchCmd{}, []string{})
 	command.ParseAndRun()
 }
 
@@ -96,16 +111,20 @@ func (cmd *helpCmd) Run(args []string) {
 	exitWithError(nil)
 }
 
-type featuresCmd struct{}
+type featuresCmd struct {
+	formatFlag
+}
 
 func (cmd *featuresCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.bindFormat(fs)
 	return fs
 }
 
 func (cmd *featuresCmd) Run(args []string) {
 	context, path := discoverContext(args)
 	exitWithError(drive.New(context, &drive.Options{
-		Path: path,
+		Path:   path,
+		Format: cmd.outputFormat(),
 	}).About(drive.AboutFeatures))
 }
 
@@ -130,35 +149,185 @@ func (cmd *initCmd) Run(args []string) {
 	exitWithError(drive.New(initContext(args), nil).Init())
 }
 
-type quotaCmd struct{}
+type quotaCmd struct {
+	formatFlag
+}
 
 func (cmd *quotaCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.bindFormat(fs)
 	return fs
 }
 
 func (cmd *quotaCmd) Run(args []string) {
 	context, path := discoverContext(args)
 	exitWithError(drive.New(context, &drive.Options{
-		Path: path,
+		Path:   path,
+		Format: cmd.outputFormat(),
 	}).About(drive.AboutQuota))
 }
 
+// formatFlag is the -format flag of the commands with machine readable output.
+type formatFlag struct {
+	format *string
+}
+
+func (ff *formatFlag) bindFormat(fs *flag.FlagSet) {
+	ff.format = fs.String(drive.CLIOptionFormat, drive.FormatText, drive.DescFormat)
+}
+
+func (ff *formatFlag) outputFormat() string {
+	exitWithError(drive.ValidateFormat(*ff.format))
+	return *ff.format
+}
+
+// templateFlag is the -template flag of the commands listing files.
+type templateFlag struct {
+	template *string
+}
+
+func (tf *templateFlag) bindTemplate(fs *flag.FlagSet) {
+	tf.template = fs.String(drive.CLIOptionTemplate, "", drive.DescTemplate)
+}
+
+func (tf *templateFlag) parsedTemplate(format string) *template.Template {
+	if *tf.template == "" {
+		return nil
+	}
+	if format != drive.FormatText {
+		exitWithError(fmt.Errorf("-%s and -%s are mutually exclusive", drive.CLIOptionTemplate, drive.CLIOptionFormat))
+	}
+	tmpl, err := drive.ParseTemplate(*tf.template)
+	exitWithError(err)
+	return tmpl
+}
+
+// filterFlags are the flags shared by the commands that accept a drive.Filter.
+type filterFlags struct {
+	minSize        *string
+	maxSize        *string
+	mime           *string
+	modifiedAfter  *string
+	modifiedBefore *string
+}
+
+func (ff *filterFlags) bind(fs *flag.FlagSet) {
+	ff.minSize = fs.String(drive.CLIOptionMinSize, "", drive.DescMinSize)
+	ff.maxSize = fs.String(drive.CLIOptionMaxSize, "", drive.DescMaxSize)
+	ff.mime = fs.String(drive.CLIOptionMime, "", drive.DescMime)
+	ff.modifiedAfter = fs.String(drive.CLIOptionModifiedAfter, "", drive.DescModifiedAfter)
+	ff.modifiedBefore = fs.String(drive.CLIOptionModifiedBefore, "", drive.DescModifiedBefore)
+}
+
+func (ff *filterFlags) filter() *drive.Filter {
+	ft, err := drive.NewFilter(*ff.minSize, *ff.maxSize, *ff.mime, *ff.modifiedAfter, *ff.modifiedBefore)
+	exitWithError(err)
+	return ft
+}
+
+type cacheCmd struct {
+	rebuild *bool
+	quiet   *bool
+}
+
+func (cmd *cacheCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.rebuild = fs.Bool("rebuild", false, "discard the cache and list all the remote metadata again")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *cacheCmd) Run(args []string) {
+	context, path := discoverContext(args)
+	exitWithError(drive.New(context, &drive.Options{
+		Path:  path,
+		Quiet: *cmd.quiet,
+	}).Cache(*cmd.rebuild))
+}
+
+type dedupeCmd struct {
+	keep     *string
+	hidden   *bool
+	noPrompt *bool
+	quiet    *bool
+	dryRun   *bool
+}
+
+func (cmd *dedupeCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.keep = fs.String("keep", "", "trash all the copies of a file but the newest or oldest")
+	cmd.hidden = fs.Bool("hidden", false, "include hidden paths")
+	cmd.noPrompt = fs.Bool("no-prompt", false, "trash the copies without prompting")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.dryRun = fs.Bool(drive.CLIOptionDryRun, false, drive.DescDryRun)
+	return fs
+}
+
+func (cmd *dedupeCmd) Run(args []string) {
+	exitWithError(drive.ValidateDedupeKeep(*cmd.keep))
+	sources, context, path := preprocessArgs(args)
+	exitWithError(drive.New(context, &drive.Options{
+		Keep:     *cmd.keep,
+		Hidden:   *cmd.hidden,
+		NoPrompt: *cmd.noPrompt,
+		Path:     path,
+		Sources:  sources,
+		Quiet:    *cmd.quiet,
+		DryRun:   *cmd.dryRun,
+	}).Dedupe())
+}
+
+type duCmd struct {
+	depth  *int
+	top    *int
+	human  *bool
+	hidden *bool
+	quiet  *bool
+}
+
+func (cmd *duCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.depth = fs.Int("depth", -1, "maximum depth of the folders printed, -1 for all of them")
+	cmd.top = fs.Int("top", 0, "only print this many of the largest folders")
+	cmd.human = fs.Bool("human", false, "print sizes with units e.g 1.20GB")
+	cmd.hidden = fs.Bool("hidden", false, "include hidden paths")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *duCmd) Run(args []string) {
+	sources, context, path := preprocessArgs(args)
+	exitWithError(drive.New(context, &drive.Options{
+		Depth:         *cmd.depth,
+		Top:           *cmd.top,
+		HumanReadable: *cmd.human,
+		Hidden:        *cmd.hidden,
+		Path:          path,
+		Sources:       sources,
+		Quiet:         *cmd.quiet,
+	}).DiskUsage())
+}
+
 type listCmd struct {
-	hidden      *bool
-	pageCount   *int
-	recursive   *bool
-	files       *bool
-	directories *bool
-	depth       *int
-	pageSize    *int64
-	longFmt     *bool
-	noPrompt    *bool
-	shared      *bool
-	inTrash     *bool
-	version     *bool
-	matches     *bool
-	owners      *bool
-	quiet       *bool
+	templateFlag
+	filterFlags
+	formatFlag
+	offline      *bool
+	hidden       *bool
+	pageCount    *int
+	recursive    *bool
+	files        *bool
+	directories  *bool
+	depth        *int
+	pageSize     *int64
+	longFmt      *bool
+	noPrompt     *bool
+	shared       *bool
+	inTrash      *bool
+	version      *bool
+	matches      *bool
+	owners       *bool
+	quiet        *bool
+	sortKey      *string
+	reverse      *bool
+	foldersFirst *bool
+	long         *bool
 }
 
 func (cmd *listCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -176,6 +345,14 @@ func (cmd *listCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.recursive = fs.Bool("r", false, "recursively list subdirectories")
 	cmd.matches = fs.Bool("matches", false, "list by prefix")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.offline = fs.Bool(drive.CLIOptionOffline, false, drive.DescOffline)
+	cmd.sortKey = fs.String(drive.CLIOptionSort, "", drive.DescSort)
+	cmd.reverse = fs.Bool(drive.CLIOptionReverse, false, drive.DescReverse)
+	cmd.foldersFirst = fs.Bool(drive.CLIOptionFoldersFirst, false, drive.DescFoldersFirst)
+	cmd.long = fs.Bool(drive.CLIOptionLong, false, drive.DescLong)
+	cmd.bind(fs)
+	cmd.bindFormat(fs)
+	cmd.bindTemplate(fs)
 
 	return fs
 }
@@ -213,21 +390,33 @@ func (cmd *listCmd) Run(args []string) {
 	if *cmd.inTrash {
 		typeMask |= drive.InTrash
 	}
-	if !*cmd.longFmt {
+	if *cmd.long {
+		typeMask |= drive.LongFormat | drive.Owners
+	}
+	if !*cmd.longFmt && !*cmd.long {
 		typeMask |= drive.Minimal
 	}
 
+	exitWithError(drive.ValidateSort(*cmd.sortKey))
+
 	options := drive.Options{
-		Depth:     *cmd.depth,
-		Hidden:    *cmd.hidden,
-		InTrash:   *cmd.inTrash,
-		PageSize:  *cmd.pageSize,
-		Path:      path,
-		NoPrompt:  *cmd.noPrompt,
-		Recursive: *cmd.recursive,
-		Sources:   sources,
-		TypeMask:  typeMask,
-		Quiet:     *cmd.quiet,
+		Depth:        *cmd.depth,
+		Hidden:       *cmd.hidden,
+		InTrash:      *cmd.inTrash,
+		PageSize:     *cmd.pageSize,
+		Path:         path,
+		NoPrompt:     *cmd.noPrompt,
+		Recursive:    *cmd.recursive,
+		Sources:      sources,
+		TypeMask:     typeMask,
+		Quiet:        *cmd.quiet,
+		Filter:       cmd.filter(),
+		Offline:      *cmd.offline,
+		Format:       cmd.outputFormat(),
+		Template:     cmd.parsedTemplate(*cmd.format),
+		Sort:         *cmd.sortKey,
+		Reverse:      *cmd.reverse,
+		FoldersFirst: *cmd.foldersFirst,
 	}
 
 	if *cmd.matches {
@@ -238,15 +427,21 @@ func (cmd *listCmd) Run(args []string) {
 }
 
 type statCmd struct {
+	templateFlag
+	formatFlag
 	hidden    *bool
 	recursive *bool
 	quiet     *bool
+	offline   *bool
 }
 
 func (cmd *statCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.hidden = fs.Bool("hidden", false, "discover hidden paths")
 	cmd.recursive = fs.Bool("r", false, "recursively discover folders")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.offline = fs.Bool(drive.CLIOptionOffline, false, drive.DescOffline)
+	cmd.bindFormat(fs)
+	cmd.bindTemplate(fs)
 	return fs
 }
 
@@ -259,10 +454,300 @@ func (cmd *statCmd) Run(args []string) {
 		Recursive: *cmd.recursive,
 		Sources:   sources,
 		Quiet:     *cmd.quiet,
+		Offline:   *cmd.offline,
+		Format:    cmd.outputFormat(),
+		Template:  cmd.parsedTemplate(*cmd.format),
 	}).Stat())
 }
 
+type statusCmd struct {
+	hidden         *bool
+	ignoreChecksum *bool
+	short          *bool
+	json           *bool
+	quiet          *bool
+}
+
+func (cmd *statusCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.hidden = fs.Bool("hidden", false, "discover hidden paths")
+	cmd.ignoreChecksum = fs.Bool(drive.CLIOptionIgnoreChecksum, true, drive.DescIgnoreChecksum)
+	cmd.short = fs.Bool("short", false, "print one line per path prefixed by a two letter code")
+	cmd.json = fs.Bool("json", false, "print the summary as JSON")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *statusCmd) Run(args []string) {
+	sources, context, path := preprocessArgs(args)
+	exitWithError(drive.New(context, &drive.Options{
+		Hidden:         *cmd.hidden,
+		IgnoreChecksum: *cmd.ignoreChecksum,
+		Path:           path,
+		Recursive:      true,
+		Sources:        sources,
+		Quiet:          *cmd.quiet,
+	}).Status(*cmd.short, *cmd.json))
+}
+
+type syncCmd struct {
+	export         *string
+	exportsDir     *string
+	hidden         *bool
+	ignoreChecksum *bool
+	ignoreConflict *bool
+	noPrompt       *bool
+	quiet          *bool
+}
+
+func (cmd *syncCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.export = fs.String(
+		"export", "", "comma separated list of formats to export your docs + sheets files")
+	cmd.exportsDir = fs.String("export-dir", "", "directory to place exports")
+	cmd.hidden = fs.Bool("hidden", false, "allows syncing of hidden paths")
+	cmd.ignoreChecksum = fs.Bool(drive.CLIOptionIgnoreChecksum, true, drive.DescIgnoreChecksum)
+	cmd.ignoreConflict = fs.Bool(drive.CLIOptionIgnoreConflict, false, drive.DescIgnoreConflict)
+	cmd.noPrompt = fs.Bool("no-prompt", false, "shows no prompt before applying the sync action")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *syncCmd) Run(args []string) {
+	sources, context, path := preprocessArgs(args)
+	exports := drive.NonEmptyTrimmedStrings(strings.Split(*cmd.export, ",")...)
+
+	exitWithError(drive.New(context, &drive.Options{
+		Exports:        uniqOrderedStr(exports),
+		ExportsDir:     strings.Trim(*cmd.exportsDir, " "),
+		Hidden:         *cmd.hidden,
+		IgnoreChecksum: *cmd.ignoreChecksum,
+		IgnoreConflict: *cmd.ignoreConflict,
+		NoPrompt:       *cmd.noPrompt,
+		Path:           path,
+		Recursive:      true,
+		Sources:        sources,
+		Quiet:          *cmd.quiet,
+	}).Sync())
+}
+
+type watchCmd struct {
+	hidden         *bool
+	ignoreChecksum *bool
+	ignoreConflict *bool
+}
+
+func (cmd *watchCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.hidden = fs.Bool("hidden", false, "allows pushing of hidden paths")
+	cmd.ignoreChecksum = fs.Bool(drive.CLIOptionIgnoreChecksum, true, drive.DescIgnoreChecksum)
+	cmd.ignoreConflict = fs.Bool(drive.CLIOptionIgnoreConflict, false, drive.DescIgnoreConflict)
+	return fs
+}
+
+func (cmd *watchCmd) Run(args []string) {
+	sources, context, path := preprocessArgs(args)
+	exitWithError(drive.New(context, &drive.Options{
+		Hidden:         *cmd.hidden,
+		IgnoreChecksum: *cmd.ignoreChecksum,
+		IgnoreConflict: *cmd.ignoreConflict,
+		NoPrompt:       true,
+		Path:           path,
+		Recursive:      true,
+		Sources:        sources,
+	}).Watch())
+}
+
+type daemonCmd struct {
+	export   *string
+	hidden   *bool
+	interval *time.Duration
+	quiet    *bool
+}
+
+func (cmd *daemonCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.export = fs.String(
+		"export", "", "comma separated list of formats to export your docs + sheets files")
+	cmd.hidden = fs.Bool("hidden", false, "allows pulling of hidden paths")
+	cmd.interval = fs.Duration("interval", drive.DefaultDaemonInterval, "how often to poll for remote changes")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *daemonCmd) Run(args []string) {
+	if len(args) >= 1 {
+		switch args[0] {
+		case drive.DaemonStatus, drive.DaemonPause, drive.DaemonResume, drive.DaemonStop:
+			context, path := discoverContext(nil)
+			exitWithError(drive.New(context, &drive.Options{
+				Path:  path,
+				Quiet: *cmd.quiet,
+			}).DaemonControl(args[0]))
+			return
+		}
+	}
+
+	sources, context, path := preprocessArgs(args)
+	exports := drive.NonEmptyTrimmedStrings(strings.Split(*cmd.export, ",")...)
+
+	exitWithError(drive.New(context, &drive.Options{
+		Exports:        uniqOrderedStr(exports),
+		Hidden:         *cmd.hidden,
+		IgnoreChecksum: true,
+		NoPrompt:       true,
+		Path:           path,
+		Recursive:      true,
+		Sources:        sources,
+		Quiet:          *cmd.quiet,
+	}).Daemon(*cmd.interval))
+}
+
+type searchCmd struct {
+	templateFlag
+	formatFlag
+	in          *string
+	recursive   *bool
+	hidden      *bool
+	files       *bool
+	directories *bool
+	longFmt     *bool
+	owners      *bool
+	version     *bool
+	quiet       *bool
+	offline     *bool
+}
+
+func (cmd *searchCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.in = fs.String("in", "", "comma separated folders to search in instead of your whole drive")
+	cmd.recursive = fs.Bool("r", false, "also search the descendants of the folders passed to -in")
+	cmd.hidden = fs.Bool("hidden", false, "list hidden matches too")
+	cmd.files = fs.Bool("f", false, "list only files")
+	cmd.directories = fs.Bool("d", false, "list only directories")
+	cmd.longFmt = fs.Bool("l", false, "long listing of matches")
+	cmd.owners = fs.Bool("owners", false, "shows the owner names per file")
+	cmd.version = fs.Bool("version", false, "show the number of times that the file has been modified on \n\t\tthe server even with changes not visible to the user")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.offline = fs.Bool(drive.CLIOptionOffline, false, drive.DescOffline)
+	cmd.bindFormat(fs)
+	cmd.bindTemplate(fs)
+	return fs
+}
+
+func (cmd *searchCmd) Run(args []string) {
+	var sources []string
+	var context *config.Context
+	var path string
+	if in := drive.NonEmptyTrimmedStrings(strings.Split(*cmd.in, ",")...); len(in) >= 1 {
+		sources, context, path = preprocessArgs(in)
+	} else {
+		context, path = discoverContext(nil)
+	}
+
+	typeMask := 0
+	if *cmd.directories {
+		typeMask |= drive.Folder
+	}
+	if *cmd.files {
+		typeMask |= drive.NonFolder
+	}
+	if *cmd.owners {
+		typeMask |= drive.Owners
+	}
+	if *cmd.version {
+		typeMask |= drive.CurrentVersion
+	}
+	if !*cmd.longFmt {
+		typeMask |= drive.Minimal
+	}
+
+	exitWithError(drive.New(context, &drive.Options{
+		Hidden:    *cmd.hidden,
+		Path:      path,
+		Recursive: *cmd.recursive,
+		Sources:   sources,
+		TypeMask:  typeMask,
+		Quiet:     *cmd.quiet,
+		Offline:   *cmd.offline,
+		Format:    cmd.outputFormat(),
+		Template:  cmd.parsedTemplate(*cmd.format),
+	}).Search(strings.Join(args, " ")))
+}
+
+type sparseCmd struct {
+	fs      *flag.FlagSet
+	exclude *bool
+	quiet   *bool
+}
+
+func (cmd *sparseCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.fs = fs
+	cmd.exclude = fs.Bool("exclude", false, "add the paths to the excluded instead of the included paths")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *sparseCmd) Run(args []string) {
+	if len(args) < 1 {
+		exitWithError(fmt.Errorf("sparse: expecting one of %s, %s, %s or %s",
+			drive.SparseAdd, drive.SparseRemove, drive.SparseList, drive.SparseReset))
+	}
+
+	action, rest := actionArgs(cmd.fs, args)
+	var sources []string
+	var context *config.Context
+	var path string
+	if len(rest) >= 1 {
+		sources, context, path = preprocessArgs(rest)
+	} else {
+		context, path = discoverContext(nil)
+	}
+
+	exitWithError(drive.New(context, &drive.Options{
+		Path:    path,
+		Sources: sources,
+		Quiet:   *cmd.quiet,
+	}).Sparse(action, *cmd.exclude))
+}
+
+type revisionsCmd struct {
+	fs     *flag.FlagSet
+	out    *string
+	export *string
+	quiet  *bool
+}
+
+func (cmd *revisionsCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.fs = fs
+	cmd.out = fs.String("o", "", "path to save the revision to, defaults to the file's name suffixed by the revision id")
+	cmd.export = fs.String("export", "", "comma separated list of formats to try exporting Google Docs revisions to, pdf by default")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *revisionsCmd) Run(args []string) {
+	if len(args) < 2 {
+		exitWithError(fmt.Errorf("revisions: expecting %s <path>, %s|%s <path> <revisionId> or %s|%s <path> [revisionId]",
+			drive.RevisionsList, drive.RevisionsGet, drive.RevisionsRestore, drive.RevisionsPin, drive.RevisionsUnpin))
+	}
+
+	action, rest := actionArgs(cmd.fs, args)
+	if len(rest) < 1 {
+		exitWithError(fmt.Errorf("revisions %s: expecting a remote path", action))
+	}
+	revId := ""
+	if len(rest) >= 2 {
+		revId = rest[1]
+	}
+	sources, context, path := preprocessArgs(rest[:1])
+
+	exitWithError(drive.New(context, &drive.Options{
+		Exports: drive.NonEmptyTrimmedStrings(strings.Split(*cmd.export, ",")...),
+		Out:     *cmd.out,
+		Path:    path,
+		Sources: sources,
+		Quiet:   *cmd.quiet,
+	}).Revisions(action, revId))
+}
+
 type pullCmd struct {
+	filterFlags
 	exportsDir        *string
 	export            *string
 	excludeOps        *string
@@ -277,6 +762,9 @@ type pullCmd struct {
 	piped             *bool
 	quiet             *bool
 	ignoreNameClashes *bool
+	fixClashes        *bool
+	dryRun            *bool
+	planOut           *string
 }
 
 func (cmd *pullCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -290,11 +778,15 @@ func (cmd *pullCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.ignoreChecksum = fs.Bool(drive.CLIOptionIgnoreChecksum, true, drive.DescIgnoreChecksum)
 	cmd.ignoreConflict = fs.Bool(drive.CLIOptionIgnoreConflict, false, drive.DescIgnoreConflict)
 	cmd.ignoreNameClashes = fs.Bool(drive.CLIOptionIgnoreNameClashes, false, drive.DescIgnoreNameClashes)
+	cmd.fixClashes = fs.Bool(drive.CLIOptionFixClashes, false, drive.DescFixClashes)
 	cmd.exportsDir = fs.String("export-dir", "", "directory to place exports")
 	cmd.matches = fs.Bool("matches", false, "search by prefix")
 	cmd.piped = fs.Bool("piped", false, "if true, read content from stdin")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.excludeOps = fs.String(drive.CLIOptionExcludeOperations, "", drive.DescExcludeOps)
+	cmd.dryRun = fs.Bool(drive.CLIOptionDryRun, false, drive.DescDryRun)
+	cmd.planOut = fs.String(drive.CLIOptionPlanOut, "", drive.DescPlanOut)
+	cmd.bind(fs)
 
 	return fs
 }
@@ -337,7 +829,11 @@ func (cmd *pullCmd) Run(args []string) {
 		Piped:             *cmd.piped,
 		Quiet:             *cmd.quiet,
 		IgnoreNameClashes: *cmd.ignoreNameClashes,
+		FixClashes:        *cmd.fixClashes,
 		ExcludeCrudMask:   excludeCrudMask,
+		DryRun:            *cmd.dryRun,
+		PlanOut:           *cmd.planOut,
+		Filter:            cmd.filter(),
 	}
 
 	if *cmd.matches {
@@ -350,6 +846,7 @@ func (cmd *pullCmd) Run(args []string) {
 }
 
 type pushCmd struct {
+	filterFlags
 	noClobber   *bool
 	hidden      *bool
 	force       *bool
@@ -363,12 +860,17 @@ type pushCmd struct {
 	// ocr when set indicates that Optical Character Recognition should be
 	// attempted on .[gif, jpg, pdf, png] uploads
 	ocr               *bool
+	pin               *bool
+	newRevision       *bool
 	ignoreChecksum    *bool
 	ignoreConflict    *bool
 	ignoreNameClashes *bool
+	fixClashes        *bool
 	quiet             *bool
 	coercedMimeKey    *string
 	excludeOps        *string
+	dryRun            *bool
+	planOut           *string
 }
 
 func (cmd *pushCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -380,13 +882,19 @@ func (cmd *pushCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.mountedPush = fs.Bool("m", false, "allows pushing of mounted paths")
 	cmd.convert = fs.Bool("convert", false, "toggles conversion of the file to its appropriate Google Doc format")
 	cmd.ocr = fs.Bool("ocr", false, "if true, attempt OCR on gif, jpg, pdf and png uploads")
+	cmd.pin = fs.Bool("pin", false, "keeps the uploaded revisions forever")
+	cmd.newRevision = fs.Bool("new-revision", true, "if false, updates overwrite the head revision instead of making a new one")
 	cmd.piped = fs.Bool("piped", false, "if true, read content from stdin")
 	cmd.ignoreChecksum = fs.Bool(drive.CLIOptionIgnoreChecksum, true, drive.DescIgnoreChecksum)
 	cmd.ignoreConflict = fs.Bool(drive.CLIOptionIgnoreConflict, false, drive.DescIgnoreConflict)
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.coercedMimeKey = fs.String(drive.CoercedMimeKeyKey, "", "the mimeType you are trying to coerce this file to be")
 	cmd.ignoreNameClashes = fs.Bool(drive.CLIOptionIgnoreNameClashes, false, drive.DescIgnoreNameClashes)
+	cmd.fixClashes = fs.Bool(drive.CLIOptionFixClashes, false, drive.DescFixClashes)
 	cmd.excludeOps = fs.String(drive.CLIOptionExcludeOperations, "", drive.DescExcludeOps)
+	cmd.dryRun = fs.Bool(drive.CLIOptionDryRun, false, drive.DescDryRun)
+	cmd.planOut = fs.String(drive.CLIOptionPlanOut, "", drive.DescPlanOut)
+	cmd.bind(fs)
 	return fs
 }
 
@@ -408,6 +916,55 @@ func (cmd *pushCmd) Run(args []string) {
 	}
 }
 
+type treeCmd struct {
+	depth       *int
+	directories *bool
+	hidden      *bool
+	sizes       *bool
+	ids         *bool
+	offline     *bool
+	quiet       *bool
+}
+
+func (cmd *treeCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.depth = fs.Int("depth", -1, "maximum depth to descend to, -1 for no limit")
+	cmd.directories = fs.Bool("d", false, "only draw folders")
+	cmd.hidden = fs.Bool("hidden", false, "draw hidden paths too")
+	cmd.sizes = fs.Bool("size", false, "show the size of each file")
+	cmd.ids = fs.Bool("id", false, "show the id of each file")
+	cmd.offline = fs.Bool(drive.CLIOptionOffline, false, drive.DescOffline)
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *treeCmd) Run(args []string) {
+	sources, context, path := preprocessArgs(args)
+
+	typeMask := 0
+	if *cmd.directories {
+		typeMask |= drive.Folder
+	}
+	if *cmd.sizes {
+		typeMask |= drive.Sizes
+	}
+	if *cmd.ids {
+		typeMask |= drive.Ids
+	}
+
+	exitWithError(drive.New(context, &drive.Options{
+		Depth:    *cmd.depth,
+		Hidden:   *cmd.hidden,
+		Offline:  *cmd.offline,
+		Path:     path,
+		Sources:  sources,
+		TypeMask: typeMask,
+		Quiet:    *cmd.quiet,
+		// Pagination prompts would break up the tree
+		NoPrompt: true,
+		PageSize: 1000,
+	}).Tree())
+}
+
 type touchCmd struct {
 	hidden    *bool
 	recursive *bool
@@ -453,6 +1010,12 @@ func (cmd *pushCmd) createPushOptions() *drive.Options {
 	if *cmd.ocr {
 		mask |= drive.OptOCR
 	}
+	if *cmd.pin {
+		mask |= drive.OptPinned
+	}
+	if !*cmd.newRevision {
+		mask |= drive.OptKeepHeadRevision
+	}
 
 	meta := map[string][]string{
 		drive.CoercedMimeKeyKey: drive.NonEmptyTrimmedStrings(*cmd.coercedMimeKey),
@@ -478,6 +1041,10 @@ func (cmd *pushCmd) createPushOptions() *drive.Options {
 		TypeMask:          mask,
 		ExcludeCrudMask:   excludeCrudMask,
 		IgnoreNameClashes: *cmd.ignoreNameClashes,
+		FixClashes:        *cmd.fixClashes,
+		DryRun:            *cmd.dryRun,
+		PlanOut:           *cmd.planOut,
+		Filter:            cmd.filter(),
 	}
 }
 
@@ -526,6 +1093,7 @@ func (cmd *pushCmd) pushMounted(args []string) {
 }
 
 type aboutCmd struct {
+	formatFlag
 	features *bool
 	quota    *bool
 	filesize *bool
@@ -537,6 +1105,7 @@ func (cmd *aboutCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.quota = fs.Bool("quota", false, "prints out quota information for this drive")
 	cmd.filesize = fs.Bool("filesize", false, "prints out information about file sizes e.g the max upload size for a specific file size")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.bindFormat(fs)
 	return fs
 }
 
@@ -554,7 +1123,8 @@ func (cmd *aboutCmd) Run(args []string) {
 		mask |= drive.AboutFileSizes
 	}
 	exitWithError(drive.New(context, &drive.Options{
-		Quiet: *cmd.quiet,
+		Quiet:  *cmd.quiet,
+		Format: cmd.outputFormat(),
 	}).About(mask))
 }
 
@@ -562,16 +1132,33 @@ type diffCmd struct {
 	hidden         *bool
 	ignoreChecksum *bool
 	quiet          *bool
+	rev            *string
+	revs           *bool
 }
 
 func (cmd *diffCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.hidden = fs.Bool("hidden", false, "allows pulling of hidden paths")
 	cmd.ignoreChecksum = fs.Bool(drive.CLIOptionIgnoreChecksum, true, drive.DescIgnoreChecksum)
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.rev = fs.String(drive.CLIOptionRev, "", drive.DescRev)
+	cmd.revs = fs.Bool(drive.CLIOptionRevs, false, drive.DescRevs)
 	return fs
 }
 
 func (cmd *diffCmd) Run(args []string) {
+	var revisionIds []string
+	if *cmd.revs {
+		if *cmd.rev != "" {
+			exitWithError(fmt.Errorf("diff: pass either -%s or -%s", drive.CLIOptionRev, drive.CLIOptionRevs))
+		}
+		if len(args) < 3 {
+			exitWithError(fmt.Errorf("diff -%s: expecting <revA> <revB> <paths...>", drive.CLIOptionRevs))
+		}
+		revisionIds, args = args[:2], args[2:]
+	} else if *cmd.rev != "" {
+		revisionIds = []string{*cmd.rev}
+	}
+
 	sources, context, path := preprocessArgs(args)
 	exitWithError(drive.New(context, &drive.Options{
 		Recursive:      true,
@@ -580,6 +1167,7 @@ func (cmd *diffCmd) Run(args []string) {
 		Sources:        sources,
 		IgnoreChecksum: *cmd.ignoreChecksum,
 		Quiet:          *cmd.quiet,
+		RevisionIds:    revisionIds,
 	}).Diff())
 }
 
@@ -631,12 +1219,14 @@ type deleteCmd struct {
 	hidden  *bool
 	matches *bool
 	quiet   *bool
+	dryRun  *bool
 }
 
 func (cmd *deleteCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.hidden = fs.Bool("hidden", false, "allows trashing hidden paths")
 	cmd.matches = fs.Bool("matches", false, "search by prefix and trash")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.dryRun = fs.Bool(drive.CLIOptionDryRun, false, drive.DescDryRun)
 	return fs
 }
 
@@ -647,6 +1237,7 @@ func (cmd *deleteCmd) Run(args []string) {
 			Path:    path,
 			Sources: sources,
 			Quiet:   *cmd.quiet,
+			DryRun:  *cmd.dryRun,
 		}).Delete())
 	} else {
 		cwd, err := os.Getwd()
@@ -656,6 +1247,7 @@ func (cmd *deleteCmd) Run(args []string) {
 			Path:    path,
 			Sources: args,
 			Quiet:   *cmd.quiet,
+			DryRun:  *cmd.dryRun,
 		}).DeleteByMatch())
 	}
 }
@@ -664,12 +1256,14 @@ type trashCmd struct {
 	hidden  *bool
 	matches *bool
 	quiet   *bool
+	dryRun  *bool
 }
 
 func (cmd *trashCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.hidden = fs.Bool("hidden", false, "allows trashing hidden paths")
 	cmd.matches = fs.Bool("matches", false, "search by prefix and trash")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.dryRun = fs.Bool(drive.CLIOptionDryRun, false, drive.DescDryRun)
 	return fs
 }
 
@@ -680,6 +1274,7 @@ func (cmd *trashCmd) Run(args []string) {
 			Path:    path,
 			Sources: sources,
 			Quiet:   *cmd.quiet,
+			DryRun:  *cmd.dryRun,
 		}).Trash())
 	} else {
 		cwd, err := os.Getwd()
@@ -689,18 +1284,76 @@ func (cmd *trashCmd) Run(args []string) {
 			Path:    path,
 			Sources: args,
 			Quiet:   *cmd.quiet,
+			DryRun:  *cmd.dryRun,
 		}).TrashByMatch())
 	}
 }
 
+type applyCmd struct {
+	noPrompt *bool
+	quiet    *bool
+}
+
+func (cmd *applyCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.noPrompt = fs.Bool("no-prompt", false, "shows no prompt before applying the plan")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *applyCmd) Run(args []string) {
+	if len(args) != 1 {
+		exitWithError(fmt.Errorf("apply: expecting <plan.json>"))
+	}
+	planPath, err := filepath.Abs(args[0])
+	exitWithError(err)
+
+	context, path := discoverContext(nil)
+	exitWithError(drive.New(context, &drive.Options{
+		Path:     path,
+		NoPrompt: *cmd.noPrompt,
+		Quiet:    *cmd.quiet,
+	}).Apply(planPath))
+}
+
+type undoCmd struct {
+	noPrompt *bool
+	quiet    *bool
+}
+
+func (cmd *undoCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.noPrompt = fs.Bool("no-prompt", false, "shows no prompt before undoing")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *undoCmd) Run(args []string) {
+	count := 1
+	if len(args) >= 1 {
+		n, err := strconv.ParseInt(args[0], 10, 0)
+		if err != nil || n < 1 {
+			exitWithError(fmt.Errorf("undo: expecting a positive count, got '%s'", args[0]))
+		}
+		count = int(n)
+	}
+
+	context, path := discoverContext(nil)
+	exitWithError(drive.New(context, &drive.Options{
+		Path:     path,
+		NoPrompt: *cmd.noPrompt,
+		Quiet:    *cmd.quiet,
+	}).Undo(count))
+}
+
 type copyCmd struct {
 	quiet     *bool
 	recursive *bool
+	dryRun    *bool
 }
 
 func (cmd *copyCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.recursive = fs.Bool("r", false, "recursive copying")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.dryRun = fs.Bool(drive.CLIOptionDryRun, false, drive.DescDryRun)
 	return fs
 }
 
@@ -714,6 +1367,7 @@ func (cmd *copyCmd) Run(args []string) {
 		Sources:   sources,
 		Recursive: *cmd.recursive,
 		Quiet:     *cmd.quiet,
+		DryRun:    *cmd.dryRun,
 	}).Copy())
 }
 
@@ -721,12 +1375,14 @@ type untrashCmd struct {
 	hidden  *bool
 	matches *bool
 	quiet   *bool
+	dryRun  *bool
 }
 
 func (cmd *untrashCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.hidden = fs.Bool("hidden", false, "allows untrashing hidden paths")
 	cmd.matches = fs.Bool("matches", false, "search by prefix and untrash")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.dryRun = fs.Bool(drive.CLIOptionDryRun, false, drive.DescDryRun)
 	return fs
 }
 
@@ -737,6 +1393,7 @@ func (cmd *untrashCmd) Run(args []string) {
 			Path:    path,
 			Sources: sources,
 			Quiet:   *cmd.quiet,
+			DryRun:  *cmd.dryRun,
 		}).Untrash())
 	} else {
 		cwd, err := os.Getwd()
@@ -746,6 +1403,7 @@ func (cmd *untrashCmd) Run(args []string) {
 			Path:    path,
 			Sources: args,
 			Quiet:   *cmd.quiet,
+			DryRun:  *cmd.dryRun,
 		}).UntrashByMatch())
 	}
 }
@@ -795,11 +1453,13 @@ func (cmd *unshareCmd) Run(args []string) {
 }
 
 type moveCmd struct {
-	quiet *bool
+	quiet  *bool
+	dryRun *bool
 }
 
 func (cmd *moveCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.dryRun = fs.Bool(drive.CLIOptionDryRun, false, drive.DescDryRun)
 	return fs
 }
 
@@ -809,6 +1469,7 @@ func (cmd *moveCmd) Run(args []string) {
 		Path:    path,
 		Sources: sources,
 		Quiet:   *cmd.quiet,
+		DryRun:  *cmd.dryRun,
 	}).Move())
 }
 
@@ -998,6 +1659,14 @@ func relativePathsOpt(root string, args []string, leastNonExistant bool) ([]stri
 	return relPaths, err
 }
 
+// actionArgs splits the action of commands such as `drive sparse add -exclude path`
+// from the rest of their arguments, parsing the flags that follow the action.
+func actionArgs(fs *flag.FlagSet, args []string) (action string, rest []string) {
+	action = args[0]
+	exitWithError(fs.Parse(args[1:]))
+	return action, fs.Args()
+}
+
 func preprocessArgs(args []string) ([]string, *config.Context, string) {
 	context, path := discoverContext(args)
 	root := context.AbsPathOf("")
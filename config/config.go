@@ -176,6 +176,74 @@ func IndicesAbsPath(dir, child string) string {
 	return path.Join(gdPath(dir), "indices", child)
 }
 
+// HistoryAbsPath returns the path of the append-only
+// log of inverse operations used by undo.
+func HistoryAbsPath(dir string) string {
+	return path.Join(gdPath(dir), "history")
+}
+
+// StashAbsPath returns the path under which local content
+// displaced by a pull is kept so that it can be restored.
+func StashAbsPath(dir, child string) string {
+	return path.Join(gdPath(dir), "stash", child)
+}
+
+// WatchLogAbsPath returns the path of the log
+// that watch writes its progress and errors to.
+func WatchLogAbsPath(dir string) string {
+	return path.Join(gdPath(dir), "watch.log")
+}
+
+// LockAbsPath returns the path of the lock that commands
+// modifying the context take so that they don't collide.
+func LockAbsPath(dir string) string {
+	return path.Join(gdPath(dir), "lock")
+}
+
+// DaemonSocketAbsPath returns the path of the Unix
+// socket through which a running daemon is controlled.
+func DaemonSocketAbsPath(dir string) string {
+	return path.Join(gdPath(dir), "daemon.sock")
+}
+
+// DaemonStateAbsPath returns the path where the daemon
+// keeps the id of the last remote change it has handled.
+func DaemonStateAbsPath(dir string) string {
+	return path.Join(gdPath(dir), "daemon.json")
+}
+
+// DaemonLogAbsPath returns the path of the log
+// that the daemon writes its progress and errors to.
+func DaemonLogAbsPath(dir string) string {
+	return path.Join(gdPath(dir), "daemon.log")
+}
+
+// SparseAbsPath returns the path of the profile listing the
+// remote subtrees that are included in or excluded from the context.
+func SparseAbsPath(dir string) string {
+	return path.Join(gdPath(dir), "sparse")
+}
+
+// CacheAbsPath returns the path of the offline
+// snapshot of the remote metadata.
+func CacheAbsPath(dir string) string {
+	return path.Join(gdPath(dir), "cache.json")
+}
+
+// GlobalIgnoreAbsPath returns the path of the user's ignore file that
+// applies to every drive context, under $XDG_CONFIG_HOME or ~/.config.
+func GlobalIgnoreAbsPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "drive", "ignore")
+}
+
 func LeastNonExistantRoot(contextAbsPath string) string {
 	last := ""
 	p := contextAbsPath
@@ -47,6 +47,9 @@ func (g *Commands) About(mask int) (err error) {
 	if err != nil {
 		return err
 	}
+	if g.records != nil {
+		return g.writeAboutRecords(about, mask)
+	}
 	printSummary(g.log, about, mask)
 
 	return nil
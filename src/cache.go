@@ -0,0 +1,398 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/odeke-em/drive/config"
+	drive "github.com/odeke-em/google-api-go-client/drive/v2"
+)
+
+// ErrNoCache is returned by offline commands run before the cache was built.
+var ErrNoCache = fmt.Errorf("no offline cache in this context, build it with `drive %s`", CacheKey)
+
+// metaCache is a snapshot of the remote metadata, kept in the .gd
+// directory and brought up to date through the changes feed.
+type metaCache struct {
+	ChangeId int64                  `json:"changeId"`
+	RootId   string                 `json:"rootId"`
+	Files    map[string]*drive.File `json:"files"`
+
+	// children indexes Files by the ids of their parents
+	children map[string][]*drive.File
+}
+
+// readMetaCache returns a nil cache if none was built yet.
+func readMetaCache(root string) (*metaCache, error) {
+	p := config.CacheAbsPath(root)
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	mc := &metaCache{}
+	if err = json.Unmarshal(data, mc); err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	if mc.Files == nil {
+		mc.Files = map[string]*drive.File{}
+	}
+	mc.index()
+	return mc, nil
+}
+
+// writeMetaCache replaces the cache in one go so
+// that readers never see a partially written one.
+func writeMetaCache(root string, mc *metaCache) error {
+	data, err := json.Marshal(mc)
+	if err != nil {
+		return err
+	}
+	p := config.CacheAbsPath(root)
+	tmp := p + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (mc *metaCache) index() {
+	mc.children = map[string][]*drive.File{}
+	for _, f := range mc.Files {
+		for _, parent := range f.Parents {
+			mc.children[parent.Id] = append(mc.children[parent.Id], f)
+		}
+	}
+}
+
+// apply records a change, the caller re-indexes once done with a batch.
+func (mc *metaCache) apply(ch *drive.Change) {
+	if ch.Id > mc.ChangeId {
+		mc.ChangeId = ch.Id
+	}
+	if ch.Deleted || ch.File == nil {
+		delete(mc.Files, ch.FileId)
+		return
+	}
+	mc.Files[ch.File.Id] = ch.File
+}
+
+func isTrashed(f *drive.File) bool {
+	return f.Labels != nil && f.Labels.Trashed
+}
+
+func (mc *metaCache) root() *drive.File {
+	if f, ok := mc.Files[mc.RootId]; ok {
+		return f
+	}
+	return &drive.File{Id: mc.RootId, Title: RemoteDriveRootPath, MimeType: DriveFolderMimeType}
+}
+
+// childrenOf returns the children of a folder, either those in the trash or the others.
+func (mc *metaCache) childrenOf(parentId string, trashed bool) (children []*drive.File) {
+	if parentId == "root" {
+		parentId = mc.RootId
+	}
+	for _, f := range mc.children[parentId] {
+		if isTrashed(f) == trashed {
+			children = append(children, f)
+		}
+	}
+	return
+}
+
+// findByPath resolves a path relative to the root, only its last
+// segment is looked up in the trash when trashed is set.
+func (mc *metaCache) findByPath(p string, trashed bool) (*drive.File, error) {
+	cur := mc.root()
+	if rootLike(p) {
+		return cur, nil
+	}
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	for i, part := range parts {
+		var next *drive.File
+		for _, child := range mc.childrenOf(cur.Id, trashed && i == len(parts)-1) {
+			if urlToPath(child.Title, true) == part {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil, ErrPathNotExists
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+// pathOf is the offline counterpart of Remote.pathOf.
+func (mc *metaCache) pathOf(f *drive.File) (string, error) {
+	var segments []string
+	for cur := f; ; {
+		segments = append([]string{urlToPath(cur.Title, true)}, segments...)
+		if len(cur.Parents) < 1 {
+			return "", fmt.Errorf("%q has no parents, not in your drive", cur.Title)
+		}
+		parent := cur.Parents[0]
+		if parent.IsRoot || parent.Id == mc.RootId {
+			return "/" + strings.Join(segments, "/"), nil
+		}
+		next, ok := mc.Files[parent.Id]
+		if !ok {
+			return "", fmt.Errorf("%q: parent %s is not cached", cur.Title, parent.Id)
+		}
+		cur = next
+	}
+}
+
+// metaCache loads the offline cache of the context the first time it is
+// needed. A nil cache is returned if none was built or it can't be read.
+func (r *Remote) metaCache() (*metaCache, error) {
+	r.cacheOnce.Do(func() {
+		if r.cacheRoot != "" {
+			r.cache, r.cacheErr = readMetaCache(r.cacheRoot)
+		}
+	})
+	return r.cache, r.cacheErr
+}
+
+// allowCachedLookups lets read-only commands resolve paths through the cache.
+func (r *Remote) allowCachedLookups() {
+	r.cachedLookups = true
+}
+
+// findByPathCached looks the path up in the cache and, instead of listing
+// each folder down from the root, confirms it by getting the file and each
+// of its ancestors. Any of them renamed, moved or trashed since the cache was
+// built fails the lookup, leaving the path to be resolved from the root.
+func (r *Remote) findByPathCached(p string) (*File, bool) {
+	mc, _ := r.metaCache()
+	if mc == nil || rootLike(p) {
+		return nil, false
+	}
+	cached, err := mc.findByPath(p, false)
+	if err != nil {
+		return nil, false
+	}
+
+	var leaf *drive.File
+	for cur := cached; ; {
+		if len(cur.Parents) < 1 {
+			return nil, false
+		}
+		f, err := r.service.Files.Get(cur.Id).Do()
+		if err != nil || isTrashed(f) || f.Title != cur.Title {
+			return nil, false
+		}
+		cachedParent := cur.Parents[0]
+		if !hasParent(f, cachedParent) {
+			return nil, false
+		}
+		if leaf == nil {
+			leaf = f
+		}
+		if cachedParent.IsRoot || cachedParent.Id == mc.RootId {
+			return NewRemoteFile(leaf), true
+		}
+		next, ok := mc.Files[cachedParent.Id]
+		if !ok {
+			return nil, false
+		}
+		cur = next
+	}
+}
+
+// hasParent tells if f is still filed under the parent recorded in the cache.
+func hasParent(f *drive.File, cachedParent *drive.ParentReference) bool {
+	for _, parent := range f.Parents {
+		if parent.Id == cachedParent.Id || (parent.IsRoot && cachedParent.IsRoot) {
+			return true
+		}
+	}
+	return false
+}
+
+// offlineCache returns the cache answering offline commands.
+func (g *Commands) offlineCache() (*metaCache, error) {
+	mc, err := g.rem.metaCache()
+	if err != nil {
+		return nil, err
+	}
+	if mc == nil {
+		return nil, ErrNoCache
+	}
+	return mc, nil
+}
+
+// findByPath resolves a remote path, from the cache when offline.
+func (g *Commands) findByPath(p string, trashed bool) (*File, error) {
+	if !g.opts.Offline {
+		if trashed {
+			return g.rem.FindByPathTrashed(p)
+		}
+		return g.rem.FindByPath(p)
+	}
+	mc, err := g.offlineCache()
+	if err != nil {
+		return nil, err
+	}
+	f, err := mc.findByPath(p, trashed)
+	if err != nil {
+		return nil, err
+	}
+	return NewRemoteFile(f), nil
+}
+
+// cachedChildren lists the children of a folder from the cache.
+func (g *Commands) cachedChildren(parentId string, trashed, hidden bool) chan *File {
+	fileChan := make(chan *File)
+	go func() {
+		defer close(fileChan)
+		mc, err := g.offlineCache()
+		if err != nil {
+			return
+		}
+		for _, f := range mc.childrenOf(parentId, trashed) {
+			if !isHidden(f.Title, hidden) {
+				fileChan <- NewRemoteFile(f)
+			}
+		}
+	}()
+	return fileChan
+}
+
+// findMatchesCached is the offline counterpart of Remote.FindMatches.
+func (g *Commands) findMatchesCached(dirPath string, keywords []string, inTrash bool) (chan *File, error) {
+	mc, err := g.offlineCache()
+	if err != nil {
+		return nil, err
+	}
+	parent, err := mc.findByPath(dirPath, false)
+	if err != nil {
+		return nil, err
+	}
+
+	fileChan := make(chan *File)
+	go func() {
+		defer close(fileChan)
+		for _, f := range mc.childrenOf(parent.Id, inTrash) {
+			title := strings.ToLower(f.Title)
+			for _, keyword := range keywords {
+				if strings.Contains(title, strings.ToLower(keyword)) {
+					fileChan <- NewRemoteFile(f)
+					break
+				}
+			}
+		}
+	}()
+	return fileChan, nil
+}
+
+// buildCache lists all the remote metadata, then catches up with the
+// changes made while doing so.
+func (g *Commands) buildCache() (*metaCache, error) {
+	about, err := g.rem.About()
+	if err != nil {
+		return nil, err
+	}
+	mc := &metaCache{
+		ChangeId: about.LargestChangeId,
+		RootId:   about.RootFolderId,
+		Files:    map[string]*drive.File{},
+	}
+
+	req := g.rem.service.Files.List()
+	req.MaxResults(1000)
+	for {
+		results, lErr := req.Do()
+		if lErr != nil {
+			return nil, lErr
+		}
+		for _, f := range results.Items {
+			mc.Files[f.Id] = f
+		}
+		if results.NextPageToken == "" {
+			break
+		}
+		req = req.PageToken(results.NextPageToken)
+	}
+	return mc, g.catchUpCache(mc)
+}
+
+// catchUpCache applies the changes made since the cache was last updated.
+func (g *Commands) catchUpCache(mc *metaCache) error {
+	changes, err := g.rem.changes(mc.ChangeId + 1)
+	if err != nil {
+		return err
+	}
+	for ch := range changes {
+		mc.apply(ch)
+	}
+	mc.index()
+	return nil
+}
+
+// Cache builds the offline cache or brings it up to date,
+// discarding it first if rebuild is set.
+func (g *Commands) Cache(rebuild bool) error {
+	unlock, err := g.lockContext()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	root := g.context.AbsPathOf("")
+	var mc *metaCache
+	if !rebuild {
+		if mc, err = readMetaCache(root); err != nil {
+			g.log.LogErrf("%v, rebuilding it\n", err)
+		}
+	}
+
+	if mc == nil {
+		if mc, err = g.buildCache(); err != nil {
+			return err
+		}
+	} else if err = g.catchUpCache(mc); err != nil {
+		return err
+	}
+
+	if err = writeMetaCache(root, mc); err != nil {
+		return err
+	}
+	g.log.Logf("Cached %d file(s) up to change %d\n", len(mc.Files), mc.ChangeId)
+	return nil
+}
+
+// refreshCache keeps a previously built cache current, it
+// is a no-op if the user never asked for one.
+func (g *Commands) refreshCache() error {
+	root := g.context.AbsPathOf("")
+	mc, err := readMetaCache(root)
+	if err != nil || mc == nil {
+		return err
+	}
+	if err = g.catchUpCache(mc); err != nil {
+		return err
+	}
+	return writeMetaCache(root, mc)
+}
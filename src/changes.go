@@ -39,6 +39,13 @@ type dirList struct {
 	local  *File
 }
 
+func (d *dirList) IsDir() bool {
+	if d.remote != nil {
+		return d.remote.IsDir
+	}
+	return d.local.IsDir
+}
+
 func (d *dirList) Name() string {
 	if d.remote != nil {
 		return d.remote.Name
@@ -91,9 +98,9 @@ func (g *Commands) pathResolve() (relPath, absPath string, err error) {
 	return
 }
 
-func (g *Commands) resolveToLocalFile(relToRoot, fsPath string) (local *File, err error) {
-	if g.opts.IgnoreRegexp != nil && g.opts.IgnoreRegexp.Match([]byte(relToRoot)) {
-		err = fmt.Errorf("\n'%s' is set to be ignored yet is being processed. Use `%s` to override this\n", relToRoot, ForceKey)
+func (g *Commands) resolveToLocalFile(relToRoot, fsPath string, isPush bool) (local *File, err error) {
+	if g.configErr != nil {
+		err = g.configErr
 		return
 	}
 
@@ -102,6 +109,12 @@ func (g *Commands) resolveToLocalFile(relToRoot, fsPath string) (local *File, er
 		err = statErr
 		return
 	}
+
+	isDir := localinfo != nil && localinfo.IsDir()
+	if g.opts.Ignorer.Match(relToRoot, isDir, isPush) {
+		err = fmt.Errorf("\n'%s' is set to be ignored yet is being processed. Use `%s` to override this\n", relToRoot, ForceKey)
+		return
+	}
 	if localinfo != nil {
 		local = NewLocalFile(fsPath, localinfo)
 	}
@@ -111,7 +124,7 @@ func (g *Commands) resolveToLocalFile(relToRoot, fsPath string) (local *File, er
 
 func (g *Commands) byRemoteResolve(relToRoot, fsPath string, r *File, isPush bool) (cl []*Change, err error) {
 	var l *File
-	l, err = g.resolveToLocalFile(relToRoot, fsPath)
+	l, err = g.resolveToLocalFile(relToRoot, fsPath, isPush)
 	if err != nil {
 		return cl, err
 	}
@@ -212,6 +225,9 @@ func (g *Commands) resolveChangeListRecv(
 	if forbiddenOp {
 		return cl, nil
 	}
+	if !g.opts.Filter.allowsChange(change) {
+		return cl, nil
+	}
 
 	change.NoClobber = g.opts.NoClobber
 	change.IgnoreChecksum = g.opts.IgnoreChecksum
@@ -238,26 +254,51 @@ func (g *Commands) resolveChangeListRecv(
 		return cl, nil
 	}
 
-	// look-up for children
-	var localChildren chan *File
-	if l == nil || !l.IsDir {
-		localChildren = make(chan *File)
-		close(localChildren)
-	} else {
-		localChildren, err = list(g.context, p, g.opts.Hidden, g.opts.IgnoreRegexp)
-		if err != nil {
-			return
+	// look-up for children, under the names that planned renames give them
+	var titles map[string]string
+	mergeChildren := func() (dirlist []*dirList, clashes []*File, err error) {
+		var localChildren chan *File
+		if l == nil || !l.IsDir {
+			localChildren = make(chan *File)
+			close(localChildren)
+		} else {
+			localChildren, err = list(g.context, p, g.opts.Hidden, g.opts.Ignorer, isPush)
+			if err != nil {
+				return
+			}
+		}
+
+		var remoteChildren chan *File
+		if r != nil {
+			remoteChildren = g.rem.FindByParentId(r.Id, g.opts.Hidden)
+			if len(titles) >= 1 {
+				remoteChildren = retitled(remoteChildren, titles)
+			}
+		} else {
+			remoteChildren = make(chan *File)
+			close(remoteChildren)
 		}
+		dirlist, clashes = merge(remoteChildren, localChildren, g.opts.IgnoreNameClashes)
+		return
 	}
 
-	var remoteChildren chan *File
-	if r != nil {
-		remoteChildren = g.rem.FindByParentId(r.Id, g.opts.Hidden)
-	} else {
-		remoteChildren = make(chan *File)
-		close(remoteChildren)
+	dirlist, clashes, err := mergeChildren()
+	if err != nil {
+		return
+	}
+
+	// Once the duplicates are renamed, every one of them can be synced. The
+	// renames are only planned, they are made along with the other changes.
+	if !g.opts.IgnoreNameClashes && len(clashes) >= 1 && g.opts.FixClashes {
+		var renames []*Change
+		if renames, titles, err = g.planClashRenames(p, r); err != nil {
+			return
+		}
+		cl = append(cl, renames...)
+		if dirlist, clashes, err = mergeChildren(); err != nil {
+			return
+		}
 	}
-	dirlist, clashes := merge(remoteChildren, localChildren, g.opts.IgnoreNameClashes)
 
 	if !g.opts.IgnoreNameClashes && len(clashes) >= 1 {
 		if rootLike(p) {
@@ -267,7 +308,8 @@ func (g *Commands) resolveChangeListRecv(
 		for _, dup := range clashes {
 			g.log.LogErrf("\033[91mX\033[00m %s/%v \"%v\"\n", p, dup.Name, dup.Id)
 		}
-		err = fmt.Errorf("clashes detected. use `%s` to override this behavior", CLIOptionIgnoreNameClashes)
+		err = fmt.Errorf("clashes detected. use `%s` to rename them or `%s` to override this behavior",
+			CLIOptionFixClashes, CLIOptionIgnoreNameClashes)
 		return
 	}
 
@@ -300,6 +342,19 @@ func (g *Commands) resolveChangeListRecv(
 				} else {
 					joined = strings.Join([]string{p, l.Name()}, "/")
 				}
+				// Local children are already filtered, remote ones are not
+				if l.local == nil && g.opts.Ignorer.matchEntry(joined, l.remote.IsDir, isPush) {
+					continue
+				}
+				// A path only on the destination that is ignored in the other
+				// direction was never transferred, it must not be deleted either.
+				destOnly := (isPush && l.local == nil) || (!isPush && l.remote == nil)
+				if destOnly && g.opts.Ignorer.matchEntry(joined, l.IsDir(), !isPush) {
+					continue
+				}
+				if g.sparseSkips(joined, l, isPush) {
+					continue
+				}
 				childChanges, cErr := g.resolveChangeListRecv(isPush, p, joined, l.remote, l.local)
 				if cErr != nil && cErr != ErrPathNotExists {
 					g.log.LogErrf("%s: %v\n", p, cErr)
@@ -448,6 +503,15 @@ func warnConflictsPersist(logy *log.Logger, conflicts []*Change) {
 	}
 }
 
+// warnRemoteModified lists the changes refused because their remote was modified
+// after being resolved, running again resolves them against the new remote.
+func warnRemoteModified(logy *log.Logger, conflicts []*Change) {
+	logy.LogErrf("These %d file(s) were modified remotely after being resolved and were left alone. Run again to resolve them anew\n", len(conflicts))
+	for _, conflict := range conflicts {
+		logy.LogErrln(conflict.Path)
+	}
+}
+
 func opChangeCount(changes []*Change) map[Operation]sizeCounter {
 	opMap := map[Operation]sizeCounter{}
 
@@ -473,7 +537,9 @@ func opChangeCount(changes []*Change) map[Operation]sizeCounter {
 func previewChanges(logy *log.Logger, cl []*Change, reduce bool, opMap map[Operation]sizeCounter) {
 	for _, c := range cl {
 		op := c.Op()
-		if op != OpNone {
+		if op == OpRename {
+			logy.Logln(c.Symbol(), c.Path, "->", c.renamedPath())
+		} else if op != OpNone {
 			logy.Logln(c.Symbol(), c.Path)
 		}
 	}
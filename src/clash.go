@@ -0,0 +1,216 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	drive "github.com/odeke-em/google-api-go-client/drive/v2"
+)
+
+// byCreation orders same-name siblings from the first created.
+type byCreation []*drive.File
+
+func (bc byCreation) Len() int      { return len(bc) }
+func (bc byCreation) Swap(i, j int) { bc[i], bc[j] = bc[j], bc[i] }
+func (bc byCreation) Less(i, j int) bool {
+	// RFC 3339 times in UTC sort lexically
+	if bc[i].CreatedDate != bc[j].CreatedDate {
+		return bc[i].CreatedDate < bc[j].CreatedDate
+	}
+	return bc[i].Id < bc[j].Id
+}
+
+// numberedTitle inserts n before the extension of a file
+// e.g "notes (1).txt", folders and dotfiles such as
+// ".bashrc" which have no extension are numbered at the end.
+func numberedTitle(title string, n int, isDir bool) string {
+	ext := ""
+	if !isDir {
+		ext = filepath.Ext(title)
+	}
+	if ext == title {
+		ext = ""
+	}
+	return fmt.Sprintf("%s (%d)%s", title[:len(title)-len(ext)], n, ext)
+}
+
+// planClashRenames plans renaming the children of a remote folder that share
+// their name with an older sibling to `name (n).ext`, the first created keeping
+// its name. It returns the renames and the planned titles keyed by file id.
+func (g *Commands) planClashRenames(p string, parent *File) (renames []*Change, titles map[string]string, err error) {
+	req := g.rem.service.Files.List()
+	req.Q(fmt.Sprintf("%s in parents and trashed=false", strconv.Quote(parent.Id)))
+	req.MaxResults(1000)
+
+	var children []*drive.File
+	for {
+		results, lErr := req.Do()
+		if lErr != nil {
+			return nil, nil, lErr
+		}
+		children = append(children, results.Items...)
+		if results.NextPageToken == "" {
+			break
+		}
+		req = req.PageToken(results.NextPageToken)
+	}
+
+	taken := map[string]bool{}
+	byName := map[string][]*drive.File{}
+	var names []string
+	for _, f := range children {
+		name := urlToPath(f.Title, true)
+		if isHidden(name, g.opts.Hidden) {
+			continue
+		}
+		if !taken[name] {
+			names = append(names, name)
+		}
+		taken[name] = true
+		byName[name] = append(byName[name], f)
+	}
+	sort.Strings(names)
+
+	dir := p
+	if rootLike(dir) {
+		dir = ""
+	}
+
+	titles = map[string]string{}
+	for _, name := range names {
+		siblings := byName[name]
+		if len(siblings) < 2 {
+			continue
+		}
+		sort.Sort(byCreation(siblings))
+
+		n := 1
+		for _, f := range siblings[1:] {
+			isDir := f.MimeType == DriveFolderMimeType
+			newTitle := numberedTitle(f.Title, n, isDir)
+			for taken[urlToPath(newTitle, true)] {
+				n += 1
+				newTitle = numberedTitle(f.Title, n, isDir)
+			}
+			n += 1
+			taken[urlToPath(newTitle, true)] = true
+
+			titles[f.Id] = newTitle
+			renames = append(renames, &Change{
+				Path:     dir + "/" + name,
+				Parent:   p,
+				Dest:     NewRemoteFile(f),
+				RenameTo: newTitle,
+			})
+		}
+	}
+	return renames, titles, nil
+}
+
+// renamedPath is the path of a file once a planned rename is made.
+func (c *Change) renamedPath() string {
+	return strings.TrimSuffix(path.Dir(c.Path), "/") + "/" + urlToPath(c.RenameTo, true)
+}
+
+// retitled passes on remote children, those with a planned
+// rename under the name they are going to be synced as.
+func retitled(children chan *File, titles map[string]string) chan *File {
+	renamed := make(chan *File)
+	go func() {
+		defer close(renamed)
+		for f := range children {
+			if title, ok := titles[f.Id]; ok {
+				f.Name = urlToPath(title, true)
+			}
+			renamed <- f
+		}
+	}()
+	return renamed
+}
+
+// renameClashes makes the renames planned by -fix-clashes ahead of the other
+// changes, which were resolved as if the renames were already made. It
+// returns the other changes and, as conflicts, the renames that failed or
+// were refused since the remote changed along with the changes under their
+// planned paths, which would otherwise be synced under a name never given.
+func (g *Commands) renameClashes(cl []*Change) (rest, conflicts []*Change) {
+	etags := map[string]string{}
+	var others []*Change
+	var failed []string
+	for _, c := range cl {
+		if c.Op() != OpRename {
+			others = append(others, c)
+			continue
+		}
+		etag := c.Dest.Etag
+		if g.opts.overwritesRemote() {
+			etag = ""
+		}
+		renamed, err := g.rem.rename(c.Dest.Id, c.RenameTo, etag)
+		if err != nil {
+			if err != ErrRemoteModified {
+				g.log.LogErrf("%s \"%s\": %v\n", c.Path, c.Dest.Id, err)
+			}
+			conflicts = append(conflicts, c)
+			failed = append(failed, c.renamedPath())
+			continue
+		}
+		etags[c.Dest.Id] = renamed.Etag
+
+		g.recordInverse(&inverseOp{
+			Kind:  InverseRename,
+			Path:  c.renamedPath(),
+			Id:    c.Dest.Id,
+			Title: c.Dest.Name,
+		})
+	}
+
+	for _, c := range others {
+		if underAny(c.Path, failed) {
+			conflicts = append(conflicts, c)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+
+	// Renaming changed the etags that the other changes were resolved with
+	for _, c := range rest {
+		for _, f := range []*File{c.Src, c.Dest} {
+			if f == nil {
+				continue
+			}
+			if etag, ok := etags[f.Id]; ok {
+				f.Etag = etag
+			}
+		}
+	}
+	return
+}
+
+// underAny tells if p is one of dirs or lies beneath one of them.
+func underAny(p string, dirs []string) bool {
+	for _, dir := range dirs {
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -18,9 +18,8 @@ import (
 	"errors"
 	"os"
 	"path"
-	"path/filepath"
-	"regexp"
-	"strings"
+	"sync"
+	"text/template"
 
 	"github.com/cheggaaa/pb"
 	"github.com/mattn/go-isatty"
@@ -48,8 +47,34 @@ type Options struct {
 	// Force once set always converts NoChange into an Addition
 	Force bool
 	// Hidden discovers hidden paths if set
-	Hidden       bool
-	IgnoreRegexp *regexp.Regexp
+	Hidden bool
+	// Ignorer matches the paths excluded by the .driveignore files and the global ignore file
+	Ignorer *Ignorer
+	// Filter restricts the files considered by their size, mime type and modification time
+	Filter *Filter
+	// Offline when set answers from the cache of remote metadata instead of the network
+	Offline bool
+	// Format is the output format of listings: text, json, csv or tsv
+	Format string
+	// Template when set renders each listed file, see fileRecord for its fields
+	Template *template.Template
+	// Sort when set orders the entries of each listed folder by name, size, modtime, version or owner
+	Sort string
+	// Reverse reverses the order of the listed entries
+	Reverse bool
+	// FoldersFirst groups folders ahead of files in listings
+	FoldersFirst bool
+	// Top when set shows only that many of the largest folders
+	Top int
+	// HumanReadable prints sizes with units instead of in bytes
+	HumanReadable bool
+	// Keep when set makes dedupe trash all the copies of a file but the newest or oldest
+	Keep string
+	// Out is the local path that a downloaded revision is saved to
+	Out string
+	// RevisionIds when set makes diff compare the local copy with the first
+	// revision of each file or, given two, those remote revisions
+	RevisionIds []string
 	// IgnoreChecksum when set avoids the step
 	// of comparing checksums as a final check.
 	IgnoreChecksum bool
@@ -80,7 +105,13 @@ type Options struct {
 	Quiet             bool
 	StdoutIsTty       bool
 	IgnoreNameClashes bool
-	ExcludeCrudMask   CrudValue
+	// FixClashes renames remote siblings with the same name instead of aborting
+	FixClashes      bool
+	ExcludeCrudMask CrudValue
+	// DryRun when set emits the resolved plan as JSON instead of applying it
+	DryRun bool
+	// PlanOut when set is the path to save the resolved plan to instead of applying it
+	PlanOut string
 }
 
 type Commands struct {
@@ -90,18 +121,43 @@ type Commands struct {
 	log     *log.Logger
 
 	progress *pb.ProgressBar
+	history  *historian
+	plan     *plan
+	// sparse restricts the remote subtrees that are materialized locally
+	sparse *sparseProfile
+	// configErr is set if the ignore files or the sparse profile could not
+	// be read, rather than silently applying nothing it fails resolution.
+	configErr error
+	// logFile when set is where logging is redirected to
+	// by long running commands, progress bars are turned off.
+	logFile *os.File
+	// records when set emits listings as JSON, CSV or TSV instead of text
+	records *recordWriter
+	// listedCount and listedBytes total the files printed, for the listing summary
+	listedCount int64
+	listedBytes int64
 }
 
 func (opts *Options) canPrompt() bool {
 	if opts == nil || !opts.StdoutIsTty {
 		return false
 	}
-	if opts.Quiet {
+	if opts.Quiet || opts.DryRun {
+		return false
+	}
+	// Spinners and prompts would be mixed in with the records
+	if opts.Template != nil || (opts.Format != "" && opts.Format != FormatText) {
 		return false
 	}
 	return !opts.NoPrompt
 }
 
+// overwritesRemote is set when remote edits made after
+// resolving may be overwritten, requests then skip If-Match.
+func (opts *Options) overwritesRemote() bool {
+	return opts.IgnoreConflict || opts.Force
+}
+
 func New(context *config.Context, opts *Options) *Commands {
 	var r *Remote
 	if context != nil {
@@ -109,48 +165,45 @@ func New(context *config.Context, opts *Options) *Commands {
 	}
 
 	stdin, stdout, stderr := os.Stdin, os.Stdout, os.Stderr
+	var configErr error
+	var sparse *sparseProfile
+	var records *recordWriter
 
 	if opts != nil {
 		// should always start with /
 		opts.Path = path.Clean(path.Join("/", opts.Path))
 
 		if !opts.Force {
-			ignoresPath := filepath.Join(context.AbsPath, DriveIgnoreSuffix)
-			opts.IgnoreRegexp = readCommentedFileCompileRegexp(ignoresPath)
+			opts.Ignorer, configErr = newIgnorer(context.AbsPath)
+		}
+		if configErr == nil && context != nil {
+			sparse, configErr = readSparse(context.AbsPath)
 		}
 
 		opts.StdoutIsTty = isatty.IsTerminal(stdout.Fd())
+		records = newRecordWriter(opts.Format, opts.Template, stdout)
 
 		if opts.Quiet {
 			stdout = nil
+		} else if opts.DryRun {
+			// Keep stdout clean for the plan
+			stdout = stderr
 		}
 	}
 
 	return &Commands{
-		context: context,
-		rem:     r,
-		opts:    opts,
-		log:     log.New(stdin, stdout, stderr),
+		context:   context,
+		rem:       r,
+		opts:      opts,
+		log:       log.New(stdin, stdout, stderr),
+		sparse:    sparse,
+		configErr: configErr,
+		records:   records,
 	}
 }
 
-func readCommentedFileCompileRegexp(p string) *regexp.Regexp {
-	clauses, err := readCommentedFile(p, "#")
-	if err != nil {
-		return nil
-	}
-	if len(clauses) < 1 {
-		return nil
-	}
-	regExComp, regErr := regexp.Compile(strings.Join(clauses, "|"))
-	if regErr != nil {
-		return nil
-	}
-	return regExComp
-}
-
 func (g *Commands) taskStart(tasks int64) {
-	if tasks > 0 {
+	if tasks > 0 && g.logFile == nil {
 		g.progress = newProgressBar(tasks)
 	}
 }
@@ -172,3 +225,46 @@ func (g *Commands) taskFinish() {
 		g.progress.Finish()
 	}
 }
+
+// playProgress carries the bytes transferred by a play to its progress bar.
+// Each play starts its own and finishes it once done, after which the bytes
+// still reported by lingering transfers are dropped.
+type playProgress struct {
+	sync.RWMutex
+	finished bool
+	ch       chan int
+	done     chan bool
+}
+
+func (g *Commands) startProgress(total int64) *playProgress {
+	g.taskStart(total)
+	p := &playProgress{ch: make(chan int), done: make(chan bool)}
+	go func() {
+		for n := range p.ch {
+			g.taskAdd(int64(n))
+		}
+		close(p.done)
+	}()
+	return p
+}
+
+// add reports n bytes, it is a noop outside of a play.
+func (p *playProgress) add(n int) {
+	if p == nil {
+		return
+	}
+	p.RLock()
+	defer p.RUnlock()
+	if !p.finished {
+		p.ch <- n
+	}
+}
+
+func (g *Commands) finishProgress(p *playProgress) {
+	p.Lock()
+	p.finished = true
+	close(p.ch)
+	p.Unlock()
+	<-p.done
+	g.taskFinish()
+}
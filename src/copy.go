@@ -41,6 +41,21 @@ func (g *Commands) Copy() error {
 		return fmt.Errorf("destination: %s err: %v", dest, err)
 	}
 
+	if g.opts.DryRun {
+		g.beginPlan(CopyKey)
+		for _, srcPath := range sources {
+			srcFile, srcErr := g.rem.FindByPath(srcPath)
+			if srcErr != nil {
+				g.log.LogErrf("%s: %v\n", srcPath, srcErr)
+				continue
+			}
+			if planErr := g.planCopy(srcFile, srcPath, dest); planErr != nil {
+				g.log.LogErrf("%s: %v\n", srcPath, planErr)
+			}
+		}
+		return g.emitPlan()
+	}
+
 	multiPaths := len(sources) > 1
 	if multiPaths {
 		if destFile != nil && !destFile.IsDir {
@@ -112,3 +127,47 @@ func (g *Commands) copy(src *File, destPath string) (*File, error) {
 
 	return destFile, nil
 }
+
+// planCopy records the copies that copy would make without making them.
+func (g *Commands) planCopy(src *File, srcPath, destPath string) error {
+	if src == nil {
+		return fmt.Errorf("non existant src")
+	}
+
+	if !src.IsDir && !src.Copyable {
+		return fmt.Errorf("%s is non-copyable", src.Name)
+	}
+
+	target := destPath
+	if !src.IsDir {
+		destFile, destErr := g.rem.FindByPath(destPath)
+		if destErr != nil && destErr != ErrPathNotExists {
+			return destErr
+		}
+		if destFile != nil && destFile.IsDir {
+			target = destPath + "/" + src.Name
+		}
+	}
+
+	g.planAdd(&planEntry{
+		Op:      PlanOpCopy,
+		Path:    srcPath,
+		Target:  target,
+		SrcId:   src.Id,
+		SrcSize: src.Size,
+		IsDir:   src.IsDir,
+	})
+
+	if !src.IsDir {
+		return nil
+	}
+
+	children := g.rem.findChildren(src.Id, false)
+	for child := range children {
+		childErr := g.planCopy(child, srcPath+"/"+child.Name, destPath+"/"+child.Name)
+		if childErr != nil {
+			return childErr
+		}
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/log"
+)
+
+const (
+	DaemonStatus = "status"
+	DaemonPause  = "pause"
+	DaemonResume = "resume"
+	DaemonStop   = "stop"
+)
+
+const DefaultDaemonInterval = time.Minute
+
+type daemonState struct {
+	ChangeId int64 `json:"changeId"`
+}
+
+type daemon struct {
+	sync.Mutex
+	interval time.Duration
+	paused   bool
+	changeId int64
+	lastPoll time.Time
+	lastErr  error
+	pulled   int
+	stop     chan bool
+}
+
+func (d *daemon) String() string {
+	d.Lock()
+	defer d.Unlock()
+
+	state := "running"
+	if d.paused {
+		state = "paused"
+	}
+	lastPoll := "never"
+	if !d.lastPoll.IsZero() {
+		lastPoll = d.lastPoll.Round(time.Second).String()
+	}
+	lastErr := "none"
+	if d.lastErr != nil {
+		lastErr = d.lastErr.Error()
+	}
+
+	return fmt.Sprintf("state: %s\ninterval: %v\nchange id: %d\nlast poll: %s\nlast error: %s\npulled: %d change(s)\n",
+		state, d.interval, d.changeId, lastPoll, lastErr, d.pulled)
+}
+
+// Daemon polls the remote changes feed every interval and pulls the affected
+// paths under the sources. It is controlled through a Unix socket in the .gd
+// directory and writes its progress and errors to a log next to it.
+func (g *Commands) Daemon(interval time.Duration) error {
+	if interval <= 0 {
+		interval = DefaultDaemonInterval
+	}
+
+	root := g.context.AbsPathOf("")
+	sockPath := config.DaemonSocketAbsPath(root)
+	if conn, err := net.Dial("unix", sockPath); err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already running in this context")
+	}
+	// Left behind by a daemon that didn't exit cleanly
+	os.Remove(sockPath)
+
+	changeId, err := g.startChangeId(root)
+	if err != nil {
+		return err
+	}
+
+	logPath := config.DaemonLogAbsPath(root)
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ln, err := net.Listen("unix", sockPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sockPath)
+	defer ln.Close()
+
+	d := &daemon{
+		interval: interval,
+		changeId: changeId,
+		stop:     make(chan bool, 1),
+	}
+
+	g.log.Logf("Polling for remote changes every %v, logging to %s\n", interval, logPath)
+	g.logFile = f
+	g.log = log.New(os.Stdin, f, f)
+	g.log.Logf("%v daemon started at change %d\n", time.Now().Round(time.Second), changeId)
+
+	go g.serveDaemon(ln, d)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	g.pollOnce(d)
+	for {
+		select {
+		case <-ticker.C:
+			g.pollOnce(d)
+		case <-d.stop:
+			g.log.Logf("%v daemon stopped\n", time.Now().Round(time.Second))
+			return nil
+		case sig := <-sigs:
+			g.log.Logf("%v daemon stopped by %v\n", time.Now().Round(time.Second), sig)
+			return nil
+		}
+	}
+}
+
+// startChangeId resumes from the last change handled, otherwise
+// only changes made from now onwards are of interest.
+func (g *Commands) startChangeId(root string) (int64, error) {
+	data, err := ioutil.ReadFile(config.DaemonStateAbsPath(root))
+	if err == nil {
+		state := &daemonState{}
+		if err = json.Unmarshal(data, state); err == nil {
+			return state.ChangeId, nil
+		}
+	}
+
+	about, err := g.rem.About()
+	if err != nil {
+		return 0, err
+	}
+	return about.LargestChangeId, nil
+}
+
+func writeDaemonState(root string, state *daemonState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(config.DaemonStateAbsPath(root), data, 0600)
+}
+
+func (g *Commands) pollOnce(d *daemon) {
+	d.Lock()
+	paused := d.paused
+	d.Unlock()
+	if paused {
+		return
+	}
+
+	pulled, err := g.pollChanges(d)
+	if err == nil {
+		// Keeps the offline cache, if one was built, current too
+		var unlock func()
+		if unlock, err = g.lockContext(); err == nil {
+			err = g.refreshCache()
+			unlock()
+		}
+	}
+	if err == ErrContextLocked {
+		g.log.LogErrf("%v busy, retrying at the next poll: %v\n", time.Now().Round(time.Second), err)
+	} else if err != nil {
+		g.log.LogErrf("%v poll: %v\n", time.Now().Round(time.Second), err)
+	}
+
+	d.Lock()
+	d.lastPoll = time.Now()
+	d.lastErr = err
+	d.pulled += pulled
+	d.Unlock()
+}
+
+// pollChanges pulls the paths of the remote changes made since the last poll.
+// The recorded change id is only advanced once the pull went through.
+func (g *Commands) pollChanges(d *daemon) (pulled int, err error) {
+	d.Lock()
+	since := d.changeId
+	d.Unlock()
+
+	changes, err := g.rem.changes(since + 1)
+	if err != nil {
+		return 0, err
+	}
+
+	largest := since
+	cache := map[string]string{}
+	paths := map[string]bool{}
+	for ch := range changes {
+		if ch.Id > largest {
+			largest = ch.Id
+		}
+		if ch.Deleted || ch.File == nil {
+			// Permanently deleted, its path can no longer be resolved
+			continue
+		}
+		p, pErr := g.rem.pathOf(ch.File, cache)
+		if pErr != nil {
+			g.log.LogErrf("%s: %v\n", ch.FileId, pErr)
+			continue
+		}
+		if g.inSources(p) && !g.ignoredPath(p, false) {
+			paths[p] = true
+		}
+	}
+
+	if len(paths) >= 1 {
+		if pulled, err = g.pullPolled(paths); err != nil {
+			return pulled, err
+		}
+	}
+
+	if largest == since {
+		return pulled, nil
+	}
+
+	d.Lock()
+	d.changeId = largest
+	d.Unlock()
+	return pulled, writeDaemonState(g.context.AbsPathOf(""), &daemonState{ChangeId: largest})
+}
+
+func (g *Commands) inSources(relToRoot string) bool {
+	for _, src := range g.opts.Sources {
+		if rootLike(src) || relToRoot == src || strings.HasPrefix(relToRoot, src+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Commands) pullPolled(paths map[string]bool) (pulled int, err error) {
+	unlock, err := g.lockContext()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	var cl []*Change
+	for _, relToRoot := range collapsePaths(paths) {
+		ccl, cErr := g.changeListResolve(relToRoot, g.context.AbsPathOf(relToRoot), false)
+		if cErr != nil {
+			g.log.LogErrf("%s: %v\n", relToRoot, cErr)
+			continue
+		}
+		cl = append(cl, ccl...)
+	}
+
+	nonConflictsPtr, conflictsPtr := g.resolveConflicts(cl, false)
+	if conflictsPtr != nil {
+		warnConflictsPersist(g.log, *conflictsPtr)
+	}
+	if nonConflictsPtr == nil || len(*nonConflictsPtr) < 1 {
+		return 0, nil
+	}
+	nonConflicts := *nonConflictsPtr
+
+	g.log.Logf("%v pulling %d change(s)\n", time.Now().Round(time.Second), len(nonConflicts))
+	previewChanges(g.log, nonConflicts, false, nil)
+
+	g.beginHistory(PullKey)
+	err = g.playPullChanges(nonConflicts, g.opts.Exports, nil)
+	g.commitHistory()
+	return len(nonConflicts), err
+}
+
+func (g *Commands) serveDaemon(ln net.Listener, d *daemon) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go g.handleDaemonRequest(conn, d)
+	}
+}
+
+func (g *Commands) handleDaemonRequest(conn net.Conn, d *daemon) {
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+
+	request := strings.TrimSpace(line)
+	switch request {
+	case DaemonStatus:
+		fmt.Fprint(conn, d)
+	case DaemonPause, DaemonResume:
+		d.Lock()
+		d.paused = request == DaemonPause
+		d.Unlock()
+		fmt.Fprintf(conn, "%sd\n", request)
+	case DaemonStop:
+		fmt.Fprintln(conn, "stopping")
+		select {
+		case d.stop <- true:
+		default:
+		}
+	default:
+		fmt.Fprintf(conn, "unknown request '%s'\n", request)
+		return
+	}
+	g.log.Logf("%v %s requested\n", time.Now().Round(time.Second), request)
+}
+
+// DaemonControl sends a request to the daemon running
+// in the context and prints out its reply.
+func (g *Commands) DaemonControl(request string) error {
+	switch request {
+	case DaemonStatus, DaemonPause, DaemonResume, DaemonStop:
+	default:
+		return fmt.Errorf("daemon: unknown request '%s', expecting one of %s, %s, %s or %s",
+			request, DaemonStatus, DaemonPause, DaemonResume, DaemonStop)
+	}
+
+	conn, err := net.Dial("unix", config.DaemonSocketAbsPath(g.context.AbsPathOf("")))
+	if err != nil {
+		return fmt.Errorf("daemon: not running in this context: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err = fmt.Fprintln(conn, request); err != nil {
+		return err
+	}
+	reply, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return err
+	}
+	g.log.Logf("%s", reply)
+	return nil
+}
@@ -0,0 +1,221 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	DedupeKeepNewest = "newest"
+	DedupeKeepOldest = "oldest"
+)
+
+// ValidateDedupeKeep checks a value passed to -keep.
+func ValidateDedupeKeep(keep string) error {
+	switch keep {
+	case "", DedupeKeepNewest, DedupeKeepOldest:
+		return nil
+	}
+	return fmt.Errorf("unknown value '%s' for keep, expecting %s or %s", keep, DedupeKeepNewest, DedupeKeepOldest)
+}
+
+type dedupeEntry struct {
+	path string
+	file *File
+}
+
+// byModTime orders the copies of a file from the oldest to the newest.
+type byModTime []*dedupeEntry
+
+func (bm byModTime) Len() int      { return len(bm) }
+func (bm byModTime) Swap(i, j int) { bm[i], bm[j] = bm[j], bm[i] }
+func (bm byModTime) Less(i, j int) bool {
+	if !bm[i].file.ModTime.Equal(bm[j].file.ModTime) {
+		return bm[i].file.ModTime.Before(bm[j].file.ModTime)
+	}
+	return bm[i].path < bm[j].path
+}
+
+// dedupeGroups holds the files sharing their content and the siblings sharing their name.
+type dedupeGroups struct {
+	byContent map[string][]*dedupeEntry
+	byName    map[string][]*dedupeEntry
+	keys      []string
+	nameKeys  []string
+	// seen holds the ids already visited, a file with several
+	// parents being reached once through each of them
+	seen map[string]bool
+}
+
+// visit reports whether a file is reached for the first time.
+func (dg *dedupeGroups) visit(f *File) bool {
+	if dg.seen[f.Id] {
+		return false
+	}
+	dg.seen[f.Id] = true
+	return true
+}
+
+func (dg *dedupeGroups) add(p string, f *File) {
+	// Google Docs have no checksum and can't be compared
+	if f.IsDir || f.Md5Checksum == "" || !dg.visit(f) {
+		return
+	}
+	key := fmt.Sprintf("%s:%d", f.Md5Checksum, f.Size)
+	if _, ok := dg.byContent[key]; !ok {
+		dg.keys = append(dg.keys, key)
+	}
+	dg.byContent[key] = append(dg.byContent[key], &dedupeEntry{path: p, file: f})
+}
+
+// dedupeWalk collects the files beneath a folder, remembering
+// which of its children have the same name.
+func (g *Commands) dedupeWalk(folder *File, p string, dg *dedupeGroups) {
+	siblings := map[string][]*dedupeEntry{}
+	var names []string
+	var subFolders []*dedupeEntry
+
+	for child := range g.rem.FindByParentId(folder.Id, g.opts.Hidden) {
+		if child == nil {
+			continue
+		}
+		entry := &dedupeEntry{path: sepJoin("/", p, child.Name), file: child}
+		if len(siblings[child.Name]) == 0 {
+			names = append(names, child.Name)
+		}
+		siblings[child.Name] = append(siblings[child.Name], entry)
+		if child.IsDir {
+			if dg.visit(child) {
+				subFolders = append(subFolders, entry)
+			}
+		} else {
+			dg.add(entry.path, child)
+		}
+	}
+
+	for _, name := range names {
+		if len(siblings[name]) >= 2 {
+			key := sepJoin("/", p, name)
+			dg.nameKeys = append(dg.nameKeys, key)
+			dg.byName[key] = siblings[name]
+		}
+	}
+
+	for _, sub := range subFolders {
+		g.dedupeWalk(sub.file, sub.path, dg)
+	}
+}
+
+// Dedupe reports the files with identical content and the siblings with
+// the same name beneath the sources. With Keep set, every copy of a file
+// but the newest or oldest one is trashed.
+func (g *Commands) Dedupe() error {
+	dg := &dedupeGroups{
+		byContent: map[string][]*dedupeEntry{},
+		byName:    map[string][]*dedupeEntry{},
+		seen:      map[string]bool{},
+	}
+
+	spin := g.playabler()
+	spin.play()
+	for _, relPath := range g.opts.Sources {
+		f, err := g.findByPath(relPath, false)
+		if err != nil {
+			spin.stop()
+			return fmt.Errorf("%s: %v", relPath, err)
+		}
+		p := relPath
+		if rootLike(p) {
+			p = ""
+		}
+		if f.IsDir {
+			if dg.visit(f) {
+				g.dedupeWalk(f, p, dg)
+			}
+		} else {
+			dg.add(relPath, f)
+		}
+	}
+	spin.stop()
+
+	var cl []*Change
+	var wasted int64
+	groupCount := 0
+	for _, key := range dg.keys {
+		copies := dg.byContent[key]
+		if len(copies) < 2 {
+			continue
+		}
+		groupCount += 1
+		sort.Sort(byModTime(copies))
+
+		size := copies[0].file.Size
+		wasted += size * int64(len(copies)-1)
+
+		kept := -1
+		switch g.opts.Keep {
+		case DedupeKeepOldest:
+			kept = 0
+		case DedupeKeepNewest:
+			kept = len(copies) - 1
+		}
+
+		g.log.Logf("%d copies of %s, md5 %s\n", len(copies), prettyBytes(size), copies[0].file.Md5Checksum)
+		for i, entry := range copies {
+			marker := " "
+			if i == kept {
+				marker = "*"
+			} else if kept >= 0 {
+				cl = append(cl, &Change{Path: entry.path, Dest: entry.file})
+			}
+			g.log.Logf(" %s %-20v %s\n", marker, entry.file.ModTime, entry.path)
+		}
+	}
+
+	for _, key := range dg.nameKeys {
+		siblings := dg.byName[key]
+		g.log.Logf("%d siblings named %s\n", len(siblings), key)
+		for _, entry := range siblings {
+			kind := "file"
+			if entry.file.IsDir {
+				kind = "folder"
+			}
+			g.log.Logf("   %-6s %-20v %s\n", kind, entry.file.ModTime, entry.file.Id)
+		}
+	}
+
+	if groupCount < 1 && len(dg.nameKeys) < 1 {
+		g.log.Logln("No duplicates found.")
+		return nil
+	}
+	g.log.Logf("\n%d file(s) with copies, wasting %s; %d name clash(es)\n",
+		groupCount, prettyBytes(wasted), len(dg.nameKeys))
+
+	if g.opts.Keep == "" || len(cl) < 1 {
+		return nil
+	}
+
+	if g.opts.DryRun {
+		return g.planTrashChangeList(cl, true, false)
+	}
+
+	ok, _ := printChangeList(g.log, cl, !g.opts.canPrompt(), false)
+	if !ok {
+		return nil
+	}
+	return g.playTrashChangeList(cl, true, false)
+}
@@ -22,6 +22,8 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+
+	drive "github.com/odeke-em/google-api-go-client/drive/v2"
 )
 
 // MaxFileSize is the max number of bytes we
@@ -31,8 +33,17 @@ const MaxFileSize = 50 * 1024 * 1024
 var Ruler = strings.Repeat("*", 4)
 
 func (g *Commands) Diff() (err error) {
-	var cl []*Change
+	var diffUtilPath string
+	diffUtilPath, err = exec.LookPath("diff")
+	if err != nil {
+		return
+	}
+
+	if len(g.opts.RevisionIds) >= 1 {
+		return g.diffRevisions(diffUtilPath)
+	}
 
+	var cl []*Change
 	for _, relToRootPath := range g.opts.Sources {
 		fsPath := g.context.AbsPathOf(relToRootPath)
 		ccl, cErr := g.changeListResolve(relToRootPath, fsPath, true)
@@ -44,12 +55,6 @@ func (g *Commands) Diff() (err error) {
 		}
 	}
 
-	var diffUtilPath string
-	diffUtilPath, err = exec.LookPath("diff")
-	if err != nil {
-		return
-	}
-
 	for _, c := range cl {
 		dErr := g.perDiff(c, diffUtilPath, ".")
 		if dErr != nil {
@@ -117,50 +122,56 @@ func (g *Commands) perDiff(change *Change, diffProgPath, cwd string) (err error)
 	}
 
 	defer g.log.Logf("\n%s\n", Ruler)
-	var frTmp, fl *os.File
-	var blob io.ReadCloser
 
+	tmpPath, err := g.downloadToTemp(r.Id, "")
 	// Clean-up
-	defer func() {
-		if frTmp != nil {
-			os.RemoveAll(frTmp.Name())
-		}
-		if fl != nil {
-			fl.Close()
-		}
-		if blob != nil {
-			blob.Close()
-		}
-	}()
-
-	blob, err = g.rem.Download(r.Id, "")
+	defer os.RemoveAll(tmpPath)
 	if err != nil {
 		return err
 	}
 
-	// Next step: Create a temp file with an obscure name unlikely to clash.
+	if l.Name != r.Name {
+		g.log.Logf("%s %s\n%s\n\n", l.Name, r.Name, Ruler)
+	} else {
+		g.log.Logf("%s\n%s\n\n", l.Name, Ruler)
+	}
+
+	runDiff(diffProgPath, cwd, l.BlobAt, tmpPath)
+	return
+}
+
+// downloadToTemp saves remote content to a temp file with an obscure
+// name unlikely to clash, the caller removes the returned path.
+func (g *Commands) downloadToTemp(id, url string) (tmpPath string, err error) {
+	var blob io.ReadCloser
+	blob, err = g.rem.Download(id, url)
+	if blob != nil {
+		defer blob.Close()
+	}
+	if err != nil {
+		return
+	}
+
 	tmpName := strings.Join([]string{
 		".",
 		fmt.Sprintf("tmp%v.tmp", rand.Int()),
 	}, "x")
 
+	var frTmp *os.File
 	frTmp, err = ioutil.TempFile(".", tmpName)
 	if err != nil {
 		return
 	}
-	_, err = io.Copy(frTmp, blob)
-	if err != nil {
-		return
-	}
+	defer frTmp.Close()
 
-	if l.Name != r.Name {
-		g.log.Logf("%s %s\n%s\n\n", l.Name, r.Name, Ruler)
-	} else {
-		g.log.Logf("%s\n%s\n\n", l.Name, Ruler)
-	}
+	tmpPath = frTmp.Name()
+	_, err = io.Copy(frTmp, blob)
+	return
+}
 
+func runDiff(diffProgPath, cwd, a, b string) {
 	diffCmd := exec.Cmd{
-		Args:   []string{diffProgPath, l.BlobAt, frTmp.Name()},
+		Args:   []string{diffProgPath, a, b},
 		Dir:    cwd,
 		Path:   diffProgPath,
 		Stdin:  nil,
@@ -170,5 +181,92 @@ func (g *Commands) perDiff(change *Change, diffProgPath, cwd string) (err error)
 
 	// Normally when elements differ diff returns a non-zero code
 	_ = diffCmd.Run()
-	return
+}
+
+// revisionDownloadURL is the content of a revision, Google
+// Docs being compared through their plain text export.
+func revisionDownloadURL(rev *drive.Revision) (string, error) {
+	if rev.DownloadUrl != "" {
+		if rev.FileSize > MaxFileSize {
+			return "", fmt.Errorf("revision %s too large for display \033[94m[%v bytes]\033[00m", rev.Id, rev.FileSize)
+		}
+		return rev.DownloadUrl, nil
+	}
+	if url, ok := rev.ExportLinks[mimeTypeFromExt("txt")]; ok {
+		return url, nil
+	}
+	return "", fmt.Errorf("revision %s has no downloadable content", rev.Id)
+}
+
+// diffRevisions compares the local copy of each source with a past
+// revision of it or, given two revision ids, those revisions.
+func (g *Commands) diffRevisions(diffProgPath string) error {
+	failed := 0
+	for _, p := range g.opts.Sources {
+		if err := g.diffRevisionsOf(diffProgPath, p); err != nil {
+			g.log.LogErrf("%s: %v\n", p, err)
+			failed += 1
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("diff: %d of %d file(s) could not be compared", failed, len(g.opts.Sources))
+	}
+	return nil
+}
+
+// diffRevisionsOf compares the requested revisions of the file at p, or its
+// only requested revision against the local copy. The downloaded revisions
+// are removed once p has been compared.
+func (g *Commands) diffRevisionsOf(diffProgPath, p string) error {
+	f, err := g.rem.FindByPath(p)
+	if err != nil {
+		return err
+	}
+	if f.IsDir {
+		return fmt.Errorf("folders have no revisions")
+	}
+
+	var paths []string
+	defer func() {
+		for _, tmpPath := range paths {
+			os.RemoveAll(tmpPath)
+		}
+	}()
+
+	g.log.Logf("File: %s\n", p)
+	for _, revId := range g.opts.RevisionIds {
+		rev, err := g.rem.revision(f.Id, revId)
+		if err != nil {
+			return err
+		}
+		url, err := revisionDownloadURL(rev)
+		if err != nil {
+			return err
+		}
+		g.log.Logf("* %-15s %-25v %s\n", "rev "+rev.Id+":", revisionModTime(rev), revisionUser(rev))
+		tmpPath, err := g.downloadToTemp(f.Id, url)
+		if tmpPath != "" {
+			paths = append(paths, tmpPath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	var a, b string
+	if len(paths) == 1 {
+		localPath := g.context.AbsPathOf(p)
+		if _, err = os.Stat(localPath); err != nil {
+			return err
+		}
+		g.log.Logf("* %-15s %s\n", "local:", localPath)
+		a, b = localPath, paths[0]
+	} else {
+		a, b = paths[0], paths[1]
+	}
+
+	g.log.Logf("%s\n\n", Ruler)
+	runDiff(diffProgPath, ".", a, b)
+	g.log.Logf("\n%s\n", Ruler)
+	return nil
 }
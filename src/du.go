@@ -0,0 +1,200 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Arbitrary value. TODO: Calibrate or calculate this value
+const maxConcurrentDuListings = 8
+
+// diskUsage is the cumulative usage of a remote folder. Google
+// Docs take up no quota and the trash is accounted for on its own.
+type diskUsage struct {
+	path       string
+	depth      int
+	bytes      int64
+	files      int64
+	nativeDocs int64
+	trashBytes int64
+	trashFiles int64
+}
+
+func (du *diskUsage) add(other *diskUsage) {
+	du.bytes += other.bytes
+	du.files += other.files
+	du.nativeDocs += other.nativeDocs
+	du.trashBytes += other.trashBytes
+	du.trashFiles += other.trashFiles
+}
+
+func isNativeDoc(f *File) bool {
+	return !f.IsDir && f.Size == 0 && strings.HasPrefix(f.MimeType, "application/vnd.google-apps.")
+}
+
+type byUsage []*diskUsage
+
+func (bu byUsage) Len() int      { return len(bu) }
+func (bu byUsage) Swap(i, j int) { bu[i], bu[j] = bu[j], bu[i] }
+func (bu byUsage) Less(i, j int) bool {
+	if bu[i].bytes != bu[j].bytes {
+		return bu[i].bytes > bu[j].bytes
+	}
+	return bu[i].path < bu[j].path
+}
+
+type byUsagePath []*diskUsage
+
+func (bp byUsagePath) Len() int           { return len(bp) }
+func (bp byUsagePath) Swap(i, j int)      { bp[i], bp[j] = bp[j], bp[i] }
+func (bp byUsagePath) Less(i, j int) bool { return bp[i].path < bp[j].path }
+
+// duWalk accumulates the usage of the folders beneath a root,
+// their listings are done concurrently but limited in number.
+type duWalk struct {
+	sync.Mutex
+	g        *Commands
+	listings chan bool
+	folders  []*diskUsage
+}
+
+func (dw *duWalk) children(parentId string, trashed bool) (children []*File) {
+	dw.listings <- true
+	defer func() { <-dw.listings }()
+
+	var fileChan chan *File
+	if trashed {
+		fileChan = dw.g.rem.FindByParentIdTrashed(parentId, dw.g.opts.Hidden)
+	} else {
+		fileChan = dw.g.rem.FindByParentId(parentId, dw.g.opts.Hidden)
+	}
+	for f := range fileChan {
+		if f != nil {
+			children = append(children, f)
+		}
+	}
+	return
+}
+
+// walk returns the usage of a folder. The contents of a trashed
+// folder are trashed too and counted as such.
+func (dw *duWalk) walk(folder *File, p string, depth int, trashed bool) *diskUsage {
+	du := &diskUsage{path: p, depth: depth}
+
+	children := dw.children(folder.Id, trashed)
+	untrashed := len(children)
+	if !trashed {
+		children = append(children, dw.children(folder.Id, true)...)
+	}
+
+	var wg sync.WaitGroup
+	subUsages := make([]*diskUsage, len(children))
+	for i, child := range children {
+		childTrashed := trashed || i >= untrashed
+		if child.IsDir {
+			wg.Add(1)
+			go func(i int, child *File, childTrashed bool) {
+				defer wg.Done()
+				subUsages[i] = dw.walk(child, strings.TrimSuffix(p, "/")+"/"+child.Name, depth+1, childTrashed)
+			}(i, child, childTrashed)
+			continue
+		}
+		switch {
+		case childTrashed:
+			du.trashBytes += child.Size
+			du.trashFiles += 1
+		case isNativeDoc(child):
+			du.nativeDocs += 1
+		default:
+			du.bytes += child.Size
+			du.files += 1
+		}
+	}
+	wg.Wait()
+
+	for _, sub := range subUsages {
+		if sub != nil {
+			du.add(sub)
+		}
+	}
+
+	if !trashed {
+		dw.Lock()
+		dw.folders = append(dw.folders, du)
+		dw.Unlock()
+	}
+	return du
+}
+
+func (g *Commands) duSize(n int64) string {
+	if g.opts.HumanReadable {
+		return prettyBytes(n)
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+// DiskUsage prints the cumulative size of the remote folders beneath
+// each source down to Depth, or only the Top largest of them.
+func (g *Commands) DiskUsage() error {
+	g.rem.allowCachedLookups()
+	for _, relPath := range g.opts.Sources {
+		f, err := g.findByPath(relPath, false)
+		if err != nil {
+			g.log.LogErrf("%s: %v\n", relPath, err)
+			continue
+		}
+		if !f.IsDir {
+			g.log.Logf("%-12s %s\n", g.duSize(f.Size), relPath)
+			continue
+		}
+
+		spin := g.playabler()
+		spin.play()
+		dw := &duWalk{g: g, listings: make(chan bool, maxConcurrentDuListings)}
+		total := dw.walk(f, relPath, 0, false)
+		spin.stop()
+
+		var shown []*diskUsage
+		for _, du := range dw.folders {
+			if g.opts.Depth < 0 || du.depth <= g.opts.Depth {
+				shown = append(shown, du)
+			}
+		}
+		if g.opts.Top > 0 {
+			sort.Sort(byUsage(shown))
+			if len(shown) > g.opts.Top {
+				shown = shown[:g.opts.Top]
+			}
+		} else {
+			sort.Sort(byUsagePath(shown))
+		}
+
+		for _, du := range shown {
+			g.log.Logf("%-12s %s\n", g.duSize(du.bytes), du.path)
+		}
+		g.log.Logf("\n%s: %s in %d file(s)\n", relPath, g.duSize(total.bytes), total.files)
+		if total.nativeDocs >= 1 {
+			g.log.Logf("%d Google Doc(s), not counted against your quota\n", total.nativeDocs)
+		}
+		if total.trashFiles >= 1 {
+			g.log.Logf("%s in %d trashed file(s)\n", g.duSize(total.trashBytes), total.trashFiles)
+		}
+	}
+	return nil
+}
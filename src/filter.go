@@ -0,0 +1,239 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter narrows down the files that are pushed, pulled or listed.
+// Folders always pass so that their contents can still be examined.
+// A nil Filter lets everything through.
+type Filter struct {
+	// MinSize and MaxSize are in bytes, 0 means unbounded
+	MinSize int64
+	MaxSize int64
+	// MimeTypes are exact types or prefixes such as "image/*"
+	MimeTypes      []string
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+}
+
+var sizeSuffixes = []struct {
+	suffix string
+	factor int64
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// NewFilter parses the values of the filter flags, it
+// returns a nil Filter if none of them were set.
+func NewFilter(minSize, maxSize, mimeTypes, modifiedAfter, modifiedBefore string) (ft *Filter, err error) {
+	ft = &Filter{}
+	if ft.MinSize, err = parseSize(minSize); err != nil {
+		return nil, err
+	}
+	if ft.MaxSize, err = parseSize(maxSize); err != nil {
+		return nil, err
+	}
+	if ft.MaxSize > 0 && ft.MinSize > ft.MaxSize {
+		return nil, fmt.Errorf("minimum size %s is larger than maximum size %s", minSize, maxSize)
+	}
+	for _, mimeType := range NonEmptyTrimmedStrings(strings.Split(mimeTypes, ",")...) {
+		ft.MimeTypes = append(ft.MimeTypes, resolveMimeType(mimeType))
+	}
+	if ft.ModifiedAfter, err = parseFilterTime(modifiedAfter); err != nil {
+		return nil, err
+	}
+	if ft.ModifiedBefore, err = parseFilterTime(modifiedBefore); err != nil {
+		return nil, err
+	}
+
+	if ft.MinSize == 0 && ft.MaxSize == 0 && len(ft.MimeTypes) < 1 &&
+		ft.ModifiedAfter.IsZero() && ft.ModifiedBefore.IsZero() {
+		return nil, nil
+	}
+	return ft, nil
+}
+
+// parseSize accepts a number of bytes optionally followed
+// by one of the binary suffixes K, M, G or T e.g 2G or 500MB.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+	trimmed := strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	factor := int64(1)
+	for _, sf := range sizeSuffixes {
+		if strings.HasSuffix(trimmed, sf.suffix) {
+			trimmed, factor = strings.TrimSuffix(trimmed, sf.suffix), sf.factor
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(factor)), nil
+}
+
+// parseFilterTime accepts a date, an RFC 3339 time or an age
+// such as 36h, 7d or 2w that is counted back from now.
+func parseFilterTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit != 0 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err == nil && n >= 0 {
+			return time.Now().Add(-time.Duration(n) * unit), nil
+		}
+	} else if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expecting a date, an RFC 3339 time or an age e.g 7d", s)
+}
+
+// resolveMimeType lets extensions such as pdf stand in for their mime type.
+func resolveMimeType(s string) string {
+	if strings.Contains(s, "/") {
+		return s
+	}
+	ext := strings.TrimPrefix(s, ".")
+	if mimeType := extMimeType(ext); mimeType != "" {
+		return mimeType
+	}
+	return s
+}
+
+func extMimeType(ext string) string {
+	if ext == "" {
+		return ""
+	}
+	if mimeType := mimeTypeFromExt(ext); mimeType != "" {
+		return mimeType
+	}
+	mimeType := mime.TypeByExtension("." + ext)
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return mimeType
+}
+
+func (ft *Filter) mimeMatches(mimeType string) bool {
+	if len(ft.MimeTypes) < 1 {
+		return true
+	}
+	for _, want := range ft.MimeTypes {
+		if strings.HasSuffix(want, "/*") {
+			if strings.HasPrefix(mimeType, strings.TrimSuffix(want, "*")) {
+				return true
+			}
+		} else if mimeType == want {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether a file passes the filter. Local files have no
+// mime type recorded so theirs is guessed from their extension.
+func (ft *Filter) Allows(f *File) bool {
+	if ft == nil || f == nil || f.IsDir {
+		return true
+	}
+	if ft.MinSize > 0 && f.Size < ft.MinSize {
+		return false
+	}
+	if ft.MaxSize > 0 && f.Size > ft.MaxSize {
+		return false
+	}
+	if !ft.ModifiedAfter.IsZero() && !f.ModTime.After(ft.ModifiedAfter) {
+		return false
+	}
+	if !ft.ModifiedBefore.IsZero() && !f.ModTime.Before(ft.ModifiedBefore) {
+		return false
+	}
+	mimeType := f.MimeType
+	if mimeType == "" {
+		mimeType = extMimeType(strings.TrimPrefix(filepath.Ext(f.Name), "."))
+	}
+	return ft.mimeMatches(mimeType)
+}
+
+// allowsChange checks the source of a change or, for a deletion, its
+// destination so that filtered out files are neither copied nor deleted.
+func (ft *Filter) allowsChange(c *Change) bool {
+	if c.Src != nil {
+		return ft.Allows(c.Src)
+	}
+	return ft.Allows(c.Dest)
+}
+
+// query translates the mime type and modification time bounds into
+// a remote search clause, sizes can't be searched for and are checked
+// once the files are listed. Folders are always matched.
+func (ft *Filter) query() string {
+	if ft == nil {
+		return ""
+	}
+
+	var clauses []string
+	var mimeClauses []string
+	for _, want := range ft.MimeTypes {
+		if strings.HasSuffix(want, "/*") {
+			mimeClauses = append(mimeClauses, fmt.Sprintf("mimeType contains '%s'", strings.TrimSuffix(want, "*")))
+		} else {
+			mimeClauses = append(mimeClauses, fmt.Sprintf("mimeType = '%s'", want))
+		}
+	}
+	if len(mimeClauses) >= 1 {
+		clauses = append(clauses, "("+strings.Join(mimeClauses, " or ")+")")
+	}
+	if !ft.ModifiedAfter.IsZero() {
+		clauses = append(clauses, fmt.Sprintf("modifiedDate > '%s'", ft.ModifiedAfter.UTC().Format(time.RFC3339)))
+	}
+	if !ft.ModifiedBefore.IsZero() {
+		clauses = append(clauses, fmt.Sprintf("modifiedDate < '%s'", ft.ModifiedBefore.UTC().Format(time.RFC3339)))
+	}
+	if len(clauses) < 1 {
+		return ""
+	}
+	return fmt.Sprintf("(mimeType = '%s' or (%s))", DriveFolderMimeType, strings.Join(clauses, " and "))
+}
@@ -0,0 +1,111 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		s     string
+		want  int64
+		fails bool
+	}{
+		{s: "", want: 0},
+		{s: "0", want: 0},
+		{s: "512", want: 512},
+		{s: "512B", want: 512},
+		{s: "1k", want: 1 << 10},
+		{s: "1K", want: 1 << 10},
+		{s: "1KB", want: 1 << 10},
+		{s: "1KiB", want: 1 << 10},
+		{s: "1.5M", want: 3 << 19},
+		{s: " 2G ", want: 2 << 30},
+		{s: "1T", want: 1 << 40},
+		{s: "-1", fails: true},
+		{s: "K", fails: true},
+		{s: "ten", fails: true},
+		{s: "1X", fails: true},
+	}
+
+	for _, tc := range cases {
+		got, err := parseSize(tc.s)
+		if tc.fails {
+			if err == nil {
+				t.Errorf("parseSize(%q) = %d, expected an error", tc.s, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSize(%q): unexpected error %v", tc.s, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseSize(%q) = %d, want %d", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestParseFilterTime(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+
+	cases := []struct {
+		s     string
+		want  time.Time
+		age   time.Duration
+		fails bool
+	}{
+		{s: "", want: time.Time{}},
+		{s: "2015-06-01T10:00:00Z", want: time.Date(2015, 6, 1, 10, 0, 0, 0, time.UTC)},
+		{s: "2015-06-01", want: time.Date(2015, 6, 1, 0, 0, 0, 0, time.Local)},
+		{s: "7d", age: 7 * day},
+		{s: "2w", age: 14 * day},
+		{s: "36h", age: 36 * time.Hour},
+		{s: "0d", age: 0},
+		{s: "-1d", fails: true},
+		{s: "-2h", fails: true},
+		{s: "d", fails: true},
+		{s: "7x", fails: true},
+		{s: "06/01/2015", fails: true},
+	}
+
+	for _, tc := range cases {
+		got, err := parseFilterTime(tc.s)
+		if tc.fails {
+			if err == nil {
+				t.Errorf("parseFilterTime(%q) = %v, expected an error", tc.s, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseFilterTime(%q): unexpected error %v", tc.s, err)
+			continue
+		}
+		if tc.want.IsZero() && tc.s != "" {
+			// Ages are counted back from the time of parsing
+			want := now.Add(-tc.age)
+			if d := got.Sub(want); d < 0 || d > time.Minute {
+				t.Errorf("parseFilterTime(%q) = %v, want about %v", tc.s, got, want)
+			}
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("parseFilterTime(%q) = %v, want %v", tc.s, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	drive "github.com/odeke-em/google-api-go-client/drive/v2"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatTSV  = "tsv"
+)
+
+// ValidateFormat checks a value passed to -format.
+func ValidateFormat(format string) error {
+	switch format {
+	case "", FormatText, FormatJSON, FormatCSV, FormatTSV:
+		return nil
+	}
+	return fmt.Errorf("unknown format '%s', expecting one of %s, %s, %s or %s",
+		format, FormatText, FormatJSON, FormatCSV, FormatTSV)
+}
+
+// record is a row of machine readable output. It is emitted
+// as is as a JSON line or flattened into its header's columns.
+type record interface {
+	header() []string
+	row() []string
+}
+
+// templateFuncs are the helpers available to -template.
+var templateFuncs = template.FuncMap{
+	"prettyBytes": prettyBytes,
+	// time formats a time with a Go layout e.g {{.ModTime | time "2006-01-02"}}
+	"time": func(layout string, t time.Time) string {
+		return t.Local().Format(layout)
+	},
+	// ago is the time elapsed since then, rounded to the second
+	"ago": func(t time.Time) string {
+		return (time.Since(t) / time.Second * time.Second).String()
+	},
+	"join": func(sep string, v []string) string {
+		return strings.Join(v, sep)
+	},
+}
+
+var templateEscapes = strings.NewReplacer(`\t`, "\t", `\n`, "\n", `\\`, `\`)
+
+// ParseTemplate parses a -template, rendered once per file with a trailing
+// newline. The \t and \n escapes stand for tabs and newlines.
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("file").Funcs(templateFuncs).Parse(templateEscapes.Replace(text))
+}
+
+// recordWriter emits records, one per line, as JSON, CSV/TSV or through
+// a template. A header is written whenever the kind of record changes.
+type recordWriter struct {
+	sync.Mutex
+	format     string
+	tmpl       *template.Template
+	out        io.Writer
+	lastHeader string
+}
+
+// newRecordWriter returns nil for the text format without a template,
+// leaving output to the loggers.
+func newRecordWriter(format string, tmpl *template.Template, out io.Writer) *recordWriter {
+	if tmpl == nil && (format == "" || format == FormatText) {
+		return nil
+	}
+	return &recordWriter{format: format, tmpl: tmpl, out: out}
+}
+
+func (rw *recordWriter) write(rec record) error {
+	rw.Lock()
+	defer rw.Unlock()
+
+	if rw.tmpl != nil {
+		if err := rw.tmpl.Execute(rw.out, rec); err != nil {
+			return err
+		}
+		_, err := io.WriteString(rw.out, "\n")
+		return err
+	}
+
+	if rw.format == FormatJSON {
+		return json.NewEncoder(rw.out).Encode(rec)
+	}
+
+	w := csv.NewWriter(rw.out)
+	if rw.format == FormatTSV {
+		w.Comma = '\t'
+	}
+	header := rec.header()
+	if joined := strings.Join(header, ","); joined != rw.lastHeader {
+		rw.lastHeader = joined
+		w.Write(header)
+	}
+	w.Write(rec.row())
+	w.Flush()
+	return w.Error()
+}
+
+type permissionRecord struct {
+	Name         string `json:"name,omitempty"`
+	EmailAddress string `json:"emailAddress,omitempty"`
+	Role         string `json:"role"`
+	Type         string `json:"type"`
+}
+
+func (pr *permissionRecord) String() string {
+	who := pr.EmailAddress
+	if who == "" {
+		who = pr.Type
+	}
+	return who + ":" + pr.Role
+}
+
+// fileRecord is the full metadata of a remote file, as emitted by
+// -format and documented for use by -template:
+//
+//	.Id, .Path, .Name, .MimeType, .Md5Checksum, .Etag  strings
+//	.IsDir, .Shared                                   booleans
+//	.Size, .Version                                   integers
+//	.ModTime                                          time.Time
+//	.Owners                                           list of names
+//	.Permissions                                      list of .Name, .EmailAddress, .Role and .Type
+type fileRecord struct {
+	Id          string              `json:"id"`
+	Path        string              `json:"path"`
+	Name        string              `json:"name"`
+	IsDir       bool                `json:"isDir"`
+	Size        int64               `json:"size"`
+	Md5Checksum string              `json:"md5Checksum,omitempty"`
+	MimeType    string              `json:"mimeType"`
+	ModTime     time.Time           `json:"modTime"`
+	Etag        string              `json:"etag,omitempty"`
+	Version     int64               `json:"version"`
+	Shared      bool                `json:"shared"`
+	Owners      []string            `json:"owners,omitempty"`
+	Permissions []*permissionRecord `json:"permissions,omitempty"`
+}
+
+func newFileRecord(p string, f *File, perms []*drive.Permission) *fileRecord {
+	fr := &fileRecord{
+		Id:          f.Id,
+		Path:        p,
+		Name:        f.Name,
+		IsDir:       f.IsDir,
+		Size:        f.Size,
+		Md5Checksum: f.Md5Checksum,
+		MimeType:    f.MimeType,
+		ModTime:     f.ModTime,
+		Etag:        f.Etag,
+		Version:     f.Version,
+		Shared:      f.Shared,
+		Owners:      f.OwnerNames,
+	}
+	if perms == nil {
+		perms = f.Permissions
+	}
+	for _, perm := range perms {
+		fr.Permissions = append(fr.Permissions, &permissionRecord{
+			Name:         perm.Name,
+			EmailAddress: perm.EmailAddress,
+			Role:         perm.Role,
+			Type:         perm.Type,
+		})
+	}
+	return fr
+}
+
+func (fr *fileRecord) header() []string {
+	return []string{"id", "path", "name", "isDir", "size", "md5Checksum", "mimeType",
+		"modTime", "etag", "version", "shared", "owners", "permissions"}
+}
+
+func (fr *fileRecord) row() []string {
+	var perms []string
+	for _, perm := range fr.Permissions {
+		perms = append(perms, perm.String())
+	}
+	return []string{fr.Id, fr.Path, fr.Name, strconv.FormatBool(fr.IsDir),
+		strconv.FormatInt(fr.Size, 10), fr.Md5Checksum, fr.MimeType,
+		fr.ModTime.Format(time.RFC3339), fr.Etag, strconv.FormatInt(fr.Version, 10),
+		strconv.FormatBool(fr.Shared), strings.Join(fr.Owners, ";"), strings.Join(perms, ";")}
+}
+
+type quotaRecord struct {
+	Name               string           `json:"name"`
+	QuotaType          string           `json:"quotaType"`
+	BytesUsed          int64            `json:"bytesUsed"`
+	BytesFree          int64            `json:"bytesFree"`
+	BytesInTrash       int64            `json:"bytesInTrash"`
+	BytesTotal         int64            `json:"bytesTotal"`
+	BytesUsedAggregate int64            `json:"bytesUsedAggregate"`
+	BytesByService     map[string]int64 `json:"bytesByService,omitempty"`
+}
+
+func newQuotaRecord(about *drive.About) *quotaRecord {
+	qr := &quotaRecord{
+		Name:               about.Name,
+		QuotaType:          about.QuotaType,
+		BytesUsed:          about.QuotaBytesUsed,
+		BytesFree:          about.QuotaBytesTotal - about.QuotaBytesUsed,
+		BytesInTrash:       about.QuotaBytesUsedInTrash,
+		BytesTotal:         about.QuotaBytesTotal,
+		BytesUsedAggregate: about.QuotaBytesUsedAggregate,
+		BytesByService:     map[string]int64{},
+	}
+	for _, quotaService := range about.QuotaBytesByService {
+		qr.BytesByService[quotaService.ServiceName] = quotaService.BytesUsed
+	}
+	return qr
+}
+
+func (qr *quotaRecord) header() []string {
+	return []string{"name", "quotaType", "bytesUsed", "bytesFree", "bytesInTrash",
+		"bytesTotal", "bytesUsedAggregate", "bytesByService"}
+}
+
+func (qr *quotaRecord) row() []string {
+	var services []string
+	for service, used := range qr.BytesByService {
+		services = append(services, fmt.Sprintf("%s:%d", service, used))
+	}
+	sort.Strings(services)
+	return []string{qr.Name, qr.QuotaType, strconv.FormatInt(qr.BytesUsed, 10),
+		strconv.FormatInt(qr.BytesFree, 10), strconv.FormatInt(qr.BytesInTrash, 10),
+		strconv.FormatInt(qr.BytesTotal, 10), strconv.FormatInt(qr.BytesUsedAggregate, 10),
+		strings.Join(services, ";")}
+}
+
+type uploadSizeRecord struct {
+	Type    string `json:"type"`
+	MaxSize int64  `json:"maxSize"`
+}
+
+func (ur *uploadSizeRecord) header() []string {
+	return []string{"type", "maxSize"}
+}
+
+func (ur *uploadSizeRecord) row() []string {
+	return []string{ur.Type, strconv.FormatInt(ur.MaxSize, 10)}
+}
+
+type featureRecord struct {
+	Feature string  `json:"feature"`
+	Rate    float64 `json:"rate"`
+}
+
+func (fr *featureRecord) header() []string {
+	return []string{"feature", "rate"}
+}
+
+func (fr *featureRecord) row() []string {
+	return []string{fr.Feature, strconv.FormatFloat(fr.Rate, 'f', -1, 64)}
+}
+
+// printFile prints a listed file in the requested format.
+func (g *Commands) printFile(f *File, opt attribute) {
+	g.listedCount++
+	g.listedBytes += f.Size
+	if g.records == nil {
+		f.pretty(g.log, opt)
+		return
+	}
+	if err := g.records.write(newFileRecord(sepJoin("/", opt.parent, f.Name), f, nil)); err != nil {
+		g.log.LogErrf("%s: %v\n", f.Name, err)
+	}
+}
+
+// writeAboutRecords is the machine readable counterpart of printSummary.
+func (g *Commands) writeAboutRecords(about *drive.About, mask int) error {
+	var recs []record
+	if quotaRequested(mask) {
+		recs = append(recs, newQuotaRecord(about))
+	}
+	if fileSizesRequested(mask) {
+		for _, uploadInfo := range about.MaxUploadSizes {
+			recs = append(recs, &uploadSizeRecord{Type: uploadInfo.Type, MaxSize: uploadInfo.Size})
+		}
+	}
+	if featuresRequested(mask) {
+		for _, feature := range about.Features {
+			if feature.FeatureName != "" {
+				recs = append(recs, &featureRecord{Feature: feature.FeatureName, Rate: feature.FeatureRate})
+			}
+		}
+	}
+	for _, rec := range recs {
+		if err := g.records.write(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -21,9 +21,14 @@ import (
 const (
 	AboutKey      = "about"
 	AllKey        = "all"
+	ApplyKey      = "apply"
+	CacheKey      = "cache"
 	CopyKey       = "copy"
+	DaemonKey     = "daemon"
+	DedupeKey     = "dedupe"
 	DeleteKey     = "delete"
 	DiffKey       = "diff"
+	DuKey         = "du"
 	EmptyTrashKey = "emptytrash"
 	FeaturesKey   = "features"
 	HelpKey       = "help"
@@ -36,15 +41,23 @@ const (
 	PushKey       = "push"
 	PubKey        = "pub"
 	RenameKey     = "rename"
+	RevisionsKey  = "revisions"
 	QuotaKey      = "quota"
+	SearchKey     = "search"
 	ShareKey      = "share"
+	SparseKey     = "sparse"
 	StatKey       = "stat"
+	StatusKey     = "status"
+	SyncKey       = "sync"
 	TouchKey      = "touch"
 	TrashKey      = "trash"
+	TreeKey       = "tree"
+	UndoKey       = "undo"
 	UnshareKey    = "unshare"
 	UntrashKey    = "untrash"
 	UnpubKey      = "unpub"
 	VersionKey    = "version"
+	WatchKey      = "watch"
 
 	CoercedMimeKeyKey = "coerced-mime"
 	ForceKey          = "force"
@@ -58,9 +71,14 @@ const (
 const (
 	DescAbout          = "print out information about your Google drive"
 	DescAll            = "print out the entire help section"
+	DescApply          = "applies a plan saved by push or pull"
+	DescCache          = "builds or refreshes the offline cache of remote metadata"
 	DescCopy           = "copy remote paths to a destination"
+	DescDaemon         = "polls for remote changes and pulls them in the background"
+	DescDedupe         = "finds duplicate remote files and same-name siblings"
 	DescDelete         = "deletes the items permanently. This operation is irreversible"
 	DescDiff           = "compares local files with their remote equivalent"
+	DescDu             = "prints the cumulative size of remote folders"
 	DescEmptyTrash     = "permanently cleans out your trash"
 	DescExcludeOps     = "exclude operations"
 	DescFeatures       = "returns information about the features of your drive"
@@ -71,16 +89,24 @@ const (
 	DescQuota          = "prints out information related to your quota space"
 	DescPublish        = "publishes a file and prints its publicly available url"
 	DescRename         = "renames a file/folder"
+	DescRevisions      = "lists, downloads, restores and pins the revisions of a remote file"
 	DescPull           = "pulls remote changes from Google Drive"
 	DescPush           = "push local changes to Google Drive"
+	DescSparse         = "restricts which remote subtrees are pulled into the context"
+	DescSearch         = "searches for remote files matching a query"
 	DescShare          = "share files with specific emails giving the specified users specifies roles and permissions"
 	DescStat           = "display information about a file"
+	DescStatus         = "summarizes the local and remote changes without applying them"
+	DescSync           = "pushes local changes and pulls remote changes in one pass"
 	DescTouch          = "updates a remote file's modification time to that currently on the server"
 	DescTrash          = "moves files to trash"
+	DescTree           = "draws the remote hierarchy as a tree"
+	DescUndo           = "reverses the most recent push, pull, sync, trash, untrash, move or rename"
 	DescUnshare        = "revoke a user's access to a file"
 	DescUntrash        = "restores files from trash to their original locations"
 	DescUnpublish      = "revokes public access to a file"
 	DescVersion        = "prints the version"
+	DescWatch          = "pushes local changes as they happen"
 	DescAccountTypes   = "\n\t* anyone.\n\t* user.\n\t* domain.\n\t* group"
 	DescRoles          = "\n\t* owner.\n\t* reader.\n\t* writer.\n\t* commenter."
 	DescIgnoreChecksum = "avoids computation of checksums as a final check." +
@@ -88,37 +114,114 @@ const (
 		"\n\t* Are on a low power device"
 	DescIgnoreConflict    = "turns off the conflict resolution safety"
 	DescIgnoreNameClashes = "ignore name clashes"
+	DescFixClashes        = "rename remote siblings with the same name to `name (n).ext` in order of creation"
+	DescDryRun            = "print the resolved plan as JSON without applying it"
+	DescPlanOut           = "save the resolved plan to this file for `drive apply` instead of applying it"
+	DescMinSize           = "only consider files at least this large e.g 10K, 500M or 2G"
+	DescMaxSize           = "only consider files at most this large e.g 10K, 500M or 2G"
+	DescMime              = "comma separated mime types or extensions to consider e.g application/pdf,image/*,docx"
+	DescModifiedAfter     = "only consider files modified after this date, RFC 3339 time or age e.g 2015-06-01 or 7d"
+	DescFormat            = "output format: text, or json, csv and tsv with one record per line"
+	DescTemplate          = "render each file with a Go text/template e.g '{{.Path}}\\t{{.Size | prettyBytes}}'"
+	DescOffline           = "answer from the offline cache built by `drive cache` instead of the network"
+	DescSort              = "sort the entries of each folder by name, size, modtime, version or owner"
+	DescReverse           = "reverse the order of the listed entries"
+	DescFoldersFirst      = "list folders ahead of files"
+	DescLong              = "long listing including the mime type, md5 checksum and owners of each file"
+	DescModifiedBefore    = "only consider files modified before this date, RFC 3339 time or age e.g 2015-06-01 or 7d"
+	DescRev               = "compare the local copy with this remote revision"
+	DescRevs              = "compare two remote revisions passed ahead of the paths: <revA> <revB> <paths...>"
 )
 
 const (
 	CLIOptionIgnoreChecksum    = "ignore-checksum"
 	CLIOptionIgnoreConflict    = "ignore-conflict"
 	CLIOptionIgnoreNameClashes = "ignore-name-clashes"
+	CLIOptionFixClashes        = "fix-clashes"
 	CLIOptionExcludeOperations = "exclude-ops"
+	CLIOptionDryRun            = "dry-run"
+	CLIOptionPlanOut           = "plan-out"
+	CLIOptionMinSize           = "min-size"
+	CLIOptionMaxSize           = "max-size"
+	CLIOptionMime              = "mime"
+	CLIOptionModifiedAfter     = "modified-after"
+	CLIOptionModifiedBefore    = "modified-before"
+	CLIOptionOffline           = "offline"
+	CLIOptionSort              = "sort"
+	CLIOptionReverse           = "reverse"
+	CLIOptionFoldersFirst      = "folders-first"
+	CLIOptionLong              = "long"
+	CLIOptionFormat            = "format"
+	CLIOptionTemplate          = "template"
+	CLIOptionRev               = "rev"
+	CLIOptionRevs              = "revs"
 )
 
 var skipChecksumNote = fmt.Sprintf(
 	"\nNote: You can skip checksum verification by passing in flag `-%s`", CLIOptionIgnoreChecksum)
 
+var dryRunNote = fmt.Sprintf(
+	"\nNote: To review the changes as JSON without applying them, pass in flag `-%s`", CLIOptionDryRun)
+
+var filterNote = fmt.Sprintf(
+	"\nNote: Files can be filtered with flags `-%s`, `-%s`, `-%s`, `-%s` and `-%s`",
+	CLIOptionMinSize, CLIOptionMaxSize, CLIOptionMime, CLIOptionModifiedAfter, CLIOptionModifiedBefore)
+
+var formatNote = fmt.Sprintf(
+	"\nNote: For scripts, pass in flag `-%s json`, `-%s csv` or `-%s tsv` to get one record per line",
+	CLIOptionFormat, CLIOptionFormat, CLIOptionFormat)
+
 var docMap = map[string][]string{
 	AboutKey: []string{
-		DescAbout,
+		DescAbout, formatNote,
+	},
+	ApplyKey: []string{
+		DescApply, "Accepts the path of a plan saved with `drive push -plan-out` or `drive pull -plan-out`",
+		"Every change records the etag and md5 checksum of its source and destination",
+		"Changes whose source or destination no longer match are refused",
 	},
 	CopyKey: []string{
-		DescCopy,
+		DescCopy, dryRunNote,
+	},
+	CacheKey: []string{
+		DescCache, "The cache is kept in .gd/cache.json and brought up to date through the changes feed",
+		"Pass `-rebuild` to discard it and list everything again",
+		"Once built, list, stat and search accept `-offline` to answer from it",
+		"and a running daemon keeps it current",
+	},
+	DaemonKey: []string{
+		DescDaemon, "Accepts multiple paths to limit the pulls to",
+		"Polls the remote changes feed at the interval set by `-interval`",
+		"A running daemon is controlled with `drive daemon status|pause|resume|stop`",
+		"Progress and errors are written to .gd/daemon.log",
+		"The daemon, watch and commands modifying the context share the lock in .gd",
+	},
+	DedupeKey: []string{
+		DescDedupe, "Accepts multiple remote paths, the current one by default",
+		"Files are duplicates if they have the same md5 checksum and size,",
+		"siblings with the same name are reported separately",
+		"Pass `-keep newest` or `-keep oldest` to trash all the other copies",
+		dryRunNote,
 	},
 	DeleteKey: []string{
-		DescDelete,
+		DescDelete, dryRunNote,
 	},
 	DiffKey: []string{
 		DescDiff, "Accepts multiple remote paths for line by line comparison",
+		"Pass `-rev <id>` to compare with a past revision or `-revs <a> <b>` to compare two revisions",
 		skipChecksumNote,
 	},
+	DuKey: []string{
+		DescDu, "Accepts multiple remote paths, the current one by default",
+		"Folders are walked concurrently down to `-depth` and their sizes printed",
+		"Pass `-top N` to only print the N largest folders and `-human` for readable sizes",
+		"Google Docs take up no quota and trashed files are totalled separately",
+	},
 	EmptyTrashKey: []string{
 		DescEmptyTrash,
 	},
 	FeaturesKey: []string{
-		DescFeatures,
+		DescFeatures, formatNote,
 	},
 	InitKey: []string{
 		DescInit, "Requests for access to your Google Drive",
@@ -128,23 +231,26 @@ var docMap = map[string][]string{
 	PullKey: []string{
 		DescPull, "Downloads content from the remote drive or modifies",
 		" local content to match that on your Google Drive",
-		skipChecksumNote,
+		skipChecksumNote, dryRunNote, filterNote,
 	},
 	PushKey: []string{
 		DescPush, "Uploads content to your Google Drive from your local path",
 		"Push comes in a couple of flavors",
 		"\t* Ordinary push: `drive push path1 path2 path3`",
 		"\t* Mounted push: `drive push -m path1 [path2 path3] drive_context_path`",
-		skipChecksumNote,
+		"Pass `-pin` to keep the uploaded revisions forever and `-new-revision=false`",
+		"to overwrite the head revision of updated files instead of adding one",
+		skipChecksumNote, dryRunNote, filterNote,
 	},
 	ListKey: []string{
 		DescList,
 		"List the information of a remote path not necessarily present locally",
 		"Allows printing of long options and by default does minimal printing",
+		filterNote, formatNote,
 	},
 	MoveKey: []string{
 		DescMove,
-		"Moves files/folders between folders",
+		"Moves files/folders between folders", dryRunNote,
 	},
 	PubKey: []string{
 		DescPublish, "Accepts multiple paths",
@@ -152,33 +258,89 @@ var docMap = map[string][]string{
 	RenameKey: []string{
 		DescRename, "Accepts <src> <newName>",
 	},
-	QuotaKey: []string{DescQuota},
+	QuotaKey: []string{DescQuota, formatNote},
+	RevisionsKey: []string{
+		DescRevisions, "Accepts `list <path>`, `get <path> <revisionId>` or `restore <path> <revisionId>`",
+		"The head revision is marked with a `*` when listing",
+		"`get` saves the revision to `-o` or else to the file's name suffixed by the revision id,",
+		"Google Docs are exported to the first of the `-export` formats available, pdf by default",
+		"`restore` makes a revision the current content of the file, it can be undone",
+		"`pin <path> [revisionId]` keeps a revision, the head one by default, forever and `unpin` reverses it",
+	},
 	ShareKey: []string{
 		DescShare, "Accepts multiple paths",
 		"Specify the emails to share with as well as the message to send them on notification",
 		"Accepted values for:\n+ accountType: ",
 		DescAccountTypes, "\n+ roles:", DescRoles,
 	},
+	SearchKey: []string{
+		DescSearch, "Terms are and-ed together, `OR` separates alternatives and `-term` negates a term",
+		"\t* words and \"quoted phrases\" search the full text and titles",
+		"\t* title:word, mime:pdf, mime:image/*, owner:email, writer:email, reader:email",
+		"\t* is:starred, is:shared, is:trashed, is:folder, is:file",
+		"\t* modified>date, modified<date, viewed>date, viewed<date where a date",
+		"\t  is e.g 2015-06-01, an RFC 3339 time or an age such as 7d",
+		"Searches your whole drive unless given folders with `-in`, add `-r` to include their descendants",
+		"\n\t$ drive search -in docs,photos -r 'mime:pdf modified>30d -title:draft'",
+		formatNote,
+	},
+	SparseKey: []string{
+		DescSparse, "Accepts `add [-exclude] paths...`, `remove paths...`, `list` or `reset`",
+		"The profile is kept in .gd/sparse as JSON",
+		"Pull skips subtrees outside the profile without listing them",
+		"Push refuses to delete remote content outside the profile",
+	},
 	StatKey: []string{
 		DescStat, "provides detailed information about a remote file",
-		"Accepts multiple paths",
+		"Accepts multiple paths", formatNote,
+	},
+	StatusKey: []string{
+		DescStatus, "Accepts multiple paths",
+		"Groups every differing path as conflicted, modified locally or remotely,",
+		"untracked, only present remotely, deleted locally or deleted remotely",
+		"Pass in `-short` for a line per path or `-json` for machine readable output",
+	},
+	SyncKey: []string{
+		DescSync, "Accepts multiple paths",
+		"Paths only changed locally are pushed and paths only changed remotely are pulled",
+		"Paths changed on both sides are conflicts and prevent the sync",
+		fmt.Sprintf("Use -%s to have the most recently modified side win", CLIOptionIgnoreConflict),
+		skipChecksumNote,
 	},
 	TouchKey: []string{
 		DescTouch, "Given a list of remote files `touch` updates their",
 		"last edit times to that currently on the server",
 	},
 	TrashKey: []string{
-		DescTrash, "Sends a list of remote files to trash",
+		DescTrash, "Sends a list of remote files to trash", dryRunNote,
+	},
+	TreeKey: []string{
+		DescTree, "Accepts multiple remote paths, the current one by default",
+		"Pass `-depth` to limit how deep it goes and `-d` to only draw folders",
+		"`-size` and `-id` annotate each entry with its size and file id",
+	},
+	UndoKey: []string{
+		DescUndo, "Accepts an optional count N of the most recent commands to undo",
+		"Every push, pull, sync, trash, untrash, move and rename records how to reverse",
+		"its changes in an append-only history kept in the .gd directory",
+		"Local content displaced by a pull is stashed in .gd/stash for 30 days or until undone",
 	},
 	UnshareKey: []string{
 		DescUnshare, "Accepts multiple paths",
 		"Accepted values for accountTypes::", DescAccountTypes,
 	},
+	WatchKey: []string{
+		DescWatch, "Accepts multiple paths",
+		"Subscribes to filesystem events through inotify, only supported on Linux",
+		"Bursts of events are debounced and only the affected paths are pushed",
+		"Paths matched by .driveignore and hidden paths are left alone",
+		"Progress and errors are written to .gd/watch.log",
+	},
 	UntrashKey: []string{
 		DescUntrash, "takes remote files out of the trash",
 		"Note: untrash is a relative path command so any resolutions are made",
 		"relative to the current working directory i.e",
-		"\n\t$ drive trash mnt/logos",
+		"\n\t$ drive trash mnt/logos", dryRunNote,
 	},
 	UnpubKey: []string{
 		DescUnpublish, "revokes public access to a list of remote files",
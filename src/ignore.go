@@ -0,0 +1,331 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	// IgnorePushPrefix scopes a pattern to push, IgnorePullPrefix to pull.
+	IgnorePushPrefix = "push:"
+	IgnorePullPrefix = "pull:"
+)
+
+const (
+	ignoreBoth = iota
+	ignorePushOnly
+	ignorePullOnly
+)
+
+// ignorePattern is a single line of an ignore file, following gitignore.
+type ignorePattern struct {
+	// scope restricts the pattern to one direction
+	scope int
+	// base is the folder, relative to the root, of the
+	// ignore file the pattern comes from. Patterns only
+	// apply to paths beneath it and are matched relative to it.
+	base string
+	// negate re-includes paths excluded by an earlier pattern
+	negate bool
+	// dirOnly patterns, written with a trailing slash, only match folders
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// Ignorer matches paths relative to the drive root against the global
+// ignore file, then the .driveignore files from the root down to the
+// path's folder, each layered over the previous ones. Files below the
+// root are read the first time their folder is visited.
+// A nil Ignorer ignores nothing.
+type Ignorer struct {
+	root   string
+	global []*ignorePattern
+
+	mu   sync.Mutex
+	dirs map[string][]*ignorePattern
+}
+
+// newIgnorer reads the global and root ignore files upfront
+// so that invalid patterns in them are reported straight away.
+func newIgnorer(root string) (*Ignorer, error) {
+	ig := &Ignorer{
+		root: root,
+		dirs: map[string][]*ignorePattern{},
+	}
+
+	var err error
+	if ig.global, err = readIgnoreFile(config.GlobalIgnoreAbsPath(), ""); err != nil {
+		return nil, err
+	}
+	if err = ig.load(""); err != nil {
+		return nil, err
+	}
+	return ig, nil
+}
+
+// readIgnoreFile parses the ignore file at p whose patterns are
+// relative to base, a missing file results in no patterns.
+func readIgnoreFile(p, base string) (patterns []*ignorePattern, err error) {
+	if p == "" {
+		return nil, nil
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		pattern, pErr := parseIgnoreLine(scanner.Text())
+		if pErr != nil {
+			return nil, fmt.Errorf("%s:%d: invalid pattern %q: %v", p, lineNo, scanner.Text(), pErr)
+		}
+		if pattern != nil {
+			pattern.base = base
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// dirPatterns returns the patterns of the .driveignore in the folder
+// relDir. A file that fails to parse is not cached so that every
+// listing of its folder reports it.
+func (ig *Ignorer) dirPatterns(relDir string) ([]*ignorePattern, error) {
+	relDir = strings.Trim(relDir, "/")
+
+	ig.mu.Lock()
+	defer ig.mu.Unlock()
+
+	if patterns, ok := ig.dirs[relDir]; ok {
+		return patterns, nil
+	}
+	patterns, err := readIgnoreFile(filepath.Join(ig.root, relDir, DriveIgnoreSuffix), relDir)
+	if err != nil {
+		return nil, err
+	}
+	ig.dirs[relDir] = patterns
+	return patterns, nil
+}
+
+// load reads the .driveignore of a folder about to be listed.
+func (ig *Ignorer) load(relDir string) error {
+	if ig == nil {
+		return nil
+	}
+	_, err := ig.dirPatterns(relDir)
+	return err
+}
+
+// forget drops the cached patterns of a folder whose .driveignore changed.
+func (ig *Ignorer) forget(relDir string) {
+	if ig == nil {
+		return
+	}
+	ig.mu.Lock()
+	delete(ig.dirs, strings.Trim(relDir, "/"))
+	ig.mu.Unlock()
+}
+
+// parseIgnoreLine returns a nil pattern for blank lines and comments.
+func parseIgnoreLine(line string) (*ignorePattern, error) {
+	line = strings.TrimRight(line, "\r")
+
+	// Trailing spaces are ignored unless escaped with a backslash
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	pattern := &ignorePattern{scope: ignoreBoth}
+	if strings.HasPrefix(line, IgnorePushPrefix) {
+		pattern.scope = ignorePushOnly
+		line = line[len(IgnorePushPrefix):]
+	} else if strings.HasPrefix(line, IgnorePullPrefix) {
+		pattern.scope = ignorePullOnly
+		line = line[len(IgnorePullPrefix):]
+	}
+	if strings.HasPrefix(line, "!") {
+		pattern.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		pattern.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// A slash anywhere but at the end anchors the pattern to the root,
+	// otherwise it matches a name at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimLeft(line, "/")
+	if line == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	expr, err := globToRegexp(line)
+	if err != nil {
+		return nil, err
+	}
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(.*/)?" + expr + "$"
+	}
+	if pattern.re, err = regexp.Compile(expr); err != nil {
+		return nil, err
+	}
+	return pattern, nil
+}
+
+// globToRegexp translates a gitignore glob where '*' and '?' don't cross
+// slashes and '**' between slashes matches any number of folders.
+func globToRegexp(glob string) (string, error) {
+	var buf bytes.Buffer
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				atStart := i == 0 || glob[i-1] == '/'
+				switch {
+				case atStart && i+2 == len(glob):
+					buf.WriteString(".*")
+					i++
+					continue
+				case atStart && glob[i+2] == '/':
+					buf.WriteString("(.*/)?")
+					i += 2
+					continue
+				}
+				i++
+			}
+			buf.WriteString("[^/]*")
+		case '?':
+			buf.WriteString("[^/]")
+		case '[':
+			j := i + 1
+			if j < len(glob) && (glob[j] == '!' || glob[j] == '^') {
+				j++
+			}
+			if j < len(glob) && glob[j] == ']' {
+				j++
+			}
+			for j < len(glob) && glob[j] != ']' {
+				j++
+			}
+			if j >= len(glob) {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + class + "]")
+			i = j
+		case '\\':
+			if i+1 >= len(glob) {
+				return "", fmt.Errorf("trailing backslash")
+			}
+			i++
+			buf.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			buf.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return buf.String(), nil
+}
+
+// Match reports whether the path relative to the root is ignored when
+// pushing or pulling, either by itself or because one of its parent folders
+// is. As with gitignore, a file cannot be re-included if a parent folder is excluded.
+func (ig *Ignorer) Match(relToRoot string, isDir, isPush bool) bool {
+	p := strings.Trim(relToRoot, "/")
+	if ig == nil || p == "" {
+		return false
+	}
+	parts := strings.Split(p, "/")
+	for i := 1; i < len(parts); i++ {
+		if ig.matchEntry(strings.Join(parts[:i], "/"), true, isPush) {
+			return true
+		}
+	}
+	return ig.matchEntry(p, isDir, isPush)
+}
+
+// matchEntry only considers the path itself, for use while
+// walking down folders that are already known not to be ignored.
+func (ig *Ignorer) matchEntry(relToRoot string, isDir, isPush bool) bool {
+	if ig == nil {
+		return false
+	}
+	p := strings.Trim(relToRoot, "/")
+
+	ignored := matchPatterns(ig.global, p, isDir, isPush, false)
+
+	// From the root's .driveignore down to that of the parent folder
+	dirs := []string{""}
+	parts := strings.Split(p, "/")
+	for i := 1; i < len(parts); i++ {
+		dirs = append(dirs, strings.Join(parts[:i], "/"))
+	}
+	for _, dir := range dirs {
+		patterns, err := ig.dirPatterns(dir)
+		if err != nil {
+			// Reported when the folder is listed
+			continue
+		}
+		ignored = matchPatterns(patterns, p, isDir, isPush, ignored)
+	}
+	return ignored
+}
+
+// matchPatterns applies patterns in order, the last matching one decides.
+func matchPatterns(patterns []*ignorePattern, p string, isDir, isPush, ignored bool) bool {
+	for _, pattern := range patterns {
+		if pattern.dirOnly && !isDir {
+			continue
+		}
+		if (pattern.scope == ignorePushOnly && !isPush) || (pattern.scope == ignorePullOnly && isPush) {
+			continue
+		}
+		rel := p
+		if pattern.base != "" {
+			if !strings.HasPrefix(p, pattern.base+"/") {
+				continue
+			}
+			rel = p[len(pattern.base)+1:]
+		}
+		if pattern.re.MatchString(rel) {
+			ignored = !pattern.negate
+		}
+	}
+	return ignored
+}
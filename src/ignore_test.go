@@ -0,0 +1,226 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	cases := []struct {
+		glob, want string
+		fails      bool
+	}{
+		{glob: "*.txt", want: `[^/]*\.txt`},
+		{glob: "a?c", want: `a[^/]c`},
+		{glob: "**/build", want: `(.*/)?build`},
+		{glob: "logs/**", want: `logs/.*`},
+		{glob: "a/**/b", want: `a/(.*/)?b`},
+		{glob: "a**b", want: `a[^/]*b`},
+		{glob: "[abc].go", want: `[abc]\.go`},
+		{glob: "[!abc].go", want: `[^abc]\.go`},
+		{glob: "[]a]", want: `[]a]`},
+		{glob: `\*.txt`, want: `\*\.txt`},
+		{glob: `a\ b`, want: `a b`},
+		{glob: "[abc", fails: true},
+		{glob: `abc\`, fails: true},
+	}
+
+	for _, tc := range cases {
+		got, err := globToRegexp(tc.glob)
+		if tc.fails {
+			if err == nil {
+				t.Errorf("globToRegexp(%q) = %q, expected an error", tc.glob, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("globToRegexp(%q): unexpected error %v", tc.glob, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("globToRegexp(%q) = %q, want %q", tc.glob, got, tc.want)
+		}
+	}
+}
+
+func TestParseIgnoreLine(t *testing.T) {
+	cases := []struct {
+		line            string
+		none, fails     bool
+		scope           int
+		negate, dirOnly bool
+		matches, misses []string
+		matchesDirsOnly []string
+	}{
+		{line: "", none: true},
+		{line: "   ", none: true},
+		{line: "# a comment", none: true},
+		{line: "/", fails: true},
+		{line: "!", fails: true},
+		{line: "[ab", fails: true},
+		{
+			line:    "*.swp",
+			matches: []string{"a.swp", "docs/a.swp", "a/b/c.swp"},
+			misses:  []string{"a.swpx", "swp"},
+		},
+		{
+			line:    "*.swp   ",
+			matches: []string{"a.swp"},
+			misses:  []string{"a.swp   "},
+		},
+		{
+			line:    `a\ `,
+			matches: []string{"a "},
+			misses:  []string{"a"},
+		},
+		{
+			line:    "\\#notes\r",
+			matches: []string{"#notes"},
+		},
+		{
+			line:    "/build",
+			matches: []string{"build"},
+			misses:  []string{"src/build"},
+		},
+		{
+			line:    "docs/*.md",
+			matches: []string{"docs/a.md"},
+			misses:  []string{"x/docs/a.md", "docs/sub/a.md"},
+		},
+		{
+			line:    "**/tmp",
+			matches: []string{"tmp", "a/tmp", "a/b/tmp"},
+			misses:  []string{"atmp"},
+		},
+		{
+			line:    "logs/**",
+			matches: []string{"logs/a", "logs/a/b"},
+			misses:  []string{"logs", "x/logs/a"},
+		},
+		{
+			line:    "a/**/b",
+			matches: []string{"a/b", "a/x/b", "a/x/y/b"},
+			misses:  []string{"a/xb"},
+		},
+		{
+			line:            "cache/",
+			dirOnly:         true,
+			matchesDirsOnly: []string{"cache", "a/cache"},
+		},
+		{
+			line:    "!keep.txt",
+			negate:  true,
+			matches: []string{"keep.txt"},
+		},
+		{
+			line:    "push:*.log",
+			scope:   ignorePushOnly,
+			matches: []string{"a.log"},
+		},
+		{
+			line:    "pull:!*.log",
+			scope:   ignorePullOnly,
+			negate:  true,
+			matches: []string{"a.log"},
+		},
+	}
+
+	for _, tc := range cases {
+		pattern, err := parseIgnoreLine(tc.line)
+		if tc.fails {
+			if err == nil {
+				t.Errorf("parseIgnoreLine(%q) expected an error", tc.line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseIgnoreLine(%q): unexpected error %v", tc.line, err)
+			continue
+		}
+		if tc.none {
+			if pattern != nil {
+				t.Errorf("parseIgnoreLine(%q) = %v, expected no pattern", tc.line, pattern.re)
+			}
+			continue
+		}
+		if pattern == nil {
+			t.Errorf("parseIgnoreLine(%q) expected a pattern", tc.line)
+			continue
+		}
+		if pattern.scope != tc.scope || pattern.negate != tc.negate || pattern.dirOnly != tc.dirOnly {
+			t.Errorf("parseIgnoreLine(%q) scope=%d negate=%v dirOnly=%v, want scope=%d negate=%v dirOnly=%v",
+				tc.line, pattern.scope, pattern.negate, pattern.dirOnly, tc.scope, tc.negate, tc.dirOnly)
+		}
+		for _, p := range tc.matches {
+			if !pattern.re.MatchString(p) {
+				t.Errorf("%q should match %q", tc.line, p)
+			}
+		}
+		for _, p := range tc.misses {
+			if pattern.re.MatchString(p) {
+				t.Errorf("%q should not match %q", tc.line, p)
+			}
+		}
+		for _, p := range tc.matchesDirsOnly {
+			if matchPatterns([]*ignorePattern{pattern}, p, false, true, false) {
+				t.Errorf("%q should not match the file %q", tc.line, p)
+			}
+			if !matchPatterns([]*ignorePattern{pattern}, p, true, true, false) {
+				t.Errorf("%q should match the folder %q", tc.line, p)
+			}
+		}
+	}
+}
+
+func TestMatchPatterns(t *testing.T) {
+	parse := func(base string, lines ...string) (patterns []*ignorePattern) {
+		for _, line := range lines {
+			pattern, err := parseIgnoreLine(line)
+			if err != nil {
+				t.Fatalf("parseIgnoreLine(%q): %v", line, err)
+			}
+			pattern.base = base
+			patterns = append(patterns, pattern)
+		}
+		return patterns
+	}
+
+	cases := []struct {
+		patterns []*ignorePattern
+		path     string
+		isDir    bool
+		isPush   bool
+		want     bool
+	}{
+		{patterns: parse("", "*.log", "!keep.log"), path: "a.log", want: true},
+		{patterns: parse("", "*.log", "!keep.log"), path: "keep.log", want: false},
+		{patterns: parse("", "!keep.log", "*.log"), path: "keep.log", want: true},
+		{patterns: parse("", "push:*.log"), path: "a.log", isPush: true, want: true},
+		{patterns: parse("", "push:*.log"), path: "a.log", isPush: false, want: false},
+		{patterns: parse("", "pull:*.log"), path: "a.log", isPush: false, want: true},
+		{patterns: parse("docs", "/draft"), path: "docs/draft", want: true},
+		{patterns: parse("docs", "/draft"), path: "draft", want: false},
+		{patterns: parse("docs", "/draft"), path: "docs/x/draft", want: false},
+		{patterns: parse("docs", "draft"), path: "docs/x/draft", want: true},
+		{patterns: parse("docs", "draft"), path: "docsdraft", want: false},
+	}
+
+	for _, tc := range cases {
+		if got := matchPatterns(tc.patterns, tc.path, tc.isDir, tc.isPush, false); got != tc.want {
+			t.Errorf("matchPatterns(%q, isDir=%v, isPush=%v) = %v, want %v", tc.path, tc.isDir, tc.isPush, got, tc.want)
+		}
+	}
+}
@@ -15,6 +15,8 @@
 package drive
 
 import (
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -29,8 +31,87 @@ const (
 	Shared
 	Owners
 	CurrentVersion
+	LongFormat
+	Sizes
+	Ids
 )
 
+const (
+	SortName    = "name"
+	SortSize    = "size"
+	SortModTime = "modtime"
+	SortVersion = "version"
+	SortOwner   = "owner"
+)
+
+// ValidateSort checks a key passed to -sort.
+func ValidateSort(key string) error {
+	switch key {
+	case "", SortName, SortSize, SortModTime, SortVersion, SortOwner:
+		return nil
+	}
+	return fmt.Errorf("unknown sort key '%s', expecting one of %s, %s, %s, %s or %s",
+		key, SortName, SortSize, SortModTime, SortVersion, SortOwner)
+}
+
+// byListOrder sorts the entries of a folder by the key, optionally
+// reversed, and with folders grouped ahead of files if requested.
+type byListOrder struct {
+	files        []*File
+	key          string
+	reverse      bool
+	foldersFirst bool
+}
+
+func (lo *byListOrder) Len() int {
+	return len(lo.files)
+}
+
+func (lo *byListOrder) Swap(i, j int) {
+	lo.files[i], lo.files[j] = lo.files[j], lo.files[i]
+}
+
+func (lo *byListOrder) Less(i, j int) bool {
+	a, b := lo.files[i], lo.files[j]
+	if lo.foldersFirst && a.IsDir != b.IsDir {
+		return a.IsDir
+	}
+	if lo.reverse {
+		a, b = b, a
+	}
+	switch lo.key {
+	case SortSize:
+		if a.Size != b.Size {
+			return a.Size < b.Size
+		}
+	case SortModTime:
+		if !a.ModTime.Equal(b.ModTime) {
+			return a.ModTime.Before(b.ModTime)
+		}
+	case SortVersion:
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+	case SortOwner:
+		if ao, bo := firstOwner(a), firstOwner(b); ao != bo {
+			return ao < bo
+		}
+	}
+	return a.Name < b.Name
+}
+
+func firstOwner(f *File) string {
+	if len(f.OwnerNames) < 1 {
+		return ""
+	}
+	return f.OwnerNames[0]
+}
+
+// sorting reports whether folder entries are buffered and sorted before printing.
+func (opts *Options) sorting() bool {
+	return opts.Sort != "" || opts.Reverse || opts.FoldersFirst
+}
+
 type attribute struct {
 	minimal bool
 	mask    int
@@ -46,7 +127,12 @@ type traversalSt struct {
 }
 
 func (g *Commands) ListMatches() error {
-	matches, err := g.rem.FindMatches(g.opts.Path, g.opts.Sources, g.opts.InTrash)
+	g.rem.allowCachedLookups()
+	findMatches := g.rem.FindMatches
+	if g.opts.Offline {
+		findMatches = g.findMatchesCached
+	}
+	matches, err := findMatches(g.opts.Path, g.opts.Sources, g.opts.InTrash)
 	if err != nil {
 		return err
 	}
@@ -80,15 +166,26 @@ func (g *Commands) ListMatches() error {
 
 	if traversalCount < 1 {
 		g.log.LogErrln("no matches found!")
+	} else {
+		g.printListSummary()
 	}
 
 	return nil
 }
 
+// printListSummary totals the listed files, listings
+// in a -format or -template are left without it.
+func (g *Commands) printListSummary() {
+	if g.records != nil {
+		return
+	}
+	g.log.Logf("%d file(s), %s\n", g.listedCount, prettyBytes(g.listedBytes))
+}
+
 func (g *Commands) List() (err error) {
-	resolver := g.rem.FindByPath
-	if g.opts.InTrash {
-		resolver = g.rem.FindByPathTrashed
+	g.rem.allowCachedLookups()
+	resolver := func(p string) (*File, error) {
+		return g.findByPath(p, g.opts.InTrash)
 	}
 
 	var kvList []*keyValue
@@ -141,6 +238,8 @@ func (g *Commands) List() (err error) {
 	}
 	spin.stop()
 
+	g.printListSummary()
+
 	// No-op for now for explicitly traversing shared content
 	if false {
 		// TODO: Allow traversal of shared content as well as designated paths
@@ -183,6 +282,14 @@ func (f *File) pretty(logy *log.Logger, opt attribute) {
 		}
 	}
 
+	if longFormat(opt.mask) {
+		md5Checksum := f.Md5Checksum
+		if md5Checksum == "" {
+			md5Checksum = "-"
+		}
+		logy.Logf(" %-40s %-32s ", f.MimeType, md5Checksum)
+	}
+
 	if owners(opt.mask) && len(f.OwnerNames) >= 1 {
 		logy.Logf(" %s ", strings.Join(f.OwnerNames, " & "))
 	}
@@ -212,7 +319,9 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 
 	f := travSt.file
 	if !f.IsDir {
-		f.pretty(g.log, opt)
+		if g.opts.Filter.Allows(f) {
+			g.printFile(f, opt)
+		}
 		return true
 	}
 
@@ -229,20 +338,11 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 		travSt.depth -= 1
 	}
 
-	expr := buildExpression(f.Id, travSt.mask, travSt.inTrash)
-
-	req := g.rem.service.Files.List()
-	req.Q(expr)
-	req.MaxResults(g.opts.PageSize)
-
-	spin.pause()
-
-	fileChan := reqDoPage(req, g.opts.Hidden, g.opts.canPrompt())
+	fileChan := g.listChildren(f, travSt.mask, travSt.inTrash, spin)
 
-	spin.play()
-
-	var children []*File
+	var children, listed []*File
 	onlyFiles := (g.opts.TypeMask & NonFolder) != 0
+	sorting := g.opts.sorting()
 
 	for file := range fileChan {
 		if file == nil {
@@ -254,6 +354,9 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 
 		if file.IsDir {
 			children = append(children, file)
+		} else if g.opts.Offline && (travSt.mask&Folder) != 0 {
+			// Covered by buildExpression when online
+			continue
 		}
 
 		// The case in which only directories wanted is covered by the buildExpression clause
@@ -263,7 +366,23 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 		if onlyFiles && file.IsDir {
 			continue
 		}
-		file.pretty(g.log, opt)
+		// Sizes can't be searched for remotely
+		if !g.opts.Filter.Allows(file) {
+			continue
+		}
+		if sorting {
+			listed = append(listed, file)
+		} else {
+			g.printFile(file, opt)
+		}
+	}
+
+	if sorting {
+		sort.Sort(&byListOrder{files: listed, key: g.opts.Sort, reverse: g.opts.Reverse, foldersFirst: g.opts.FoldersFirst})
+		for _, file := range listed {
+			g.printFile(file, opt)
+		}
+		sort.Sort(&byListOrder{files: children, key: g.opts.Sort, reverse: g.opts.Reverse})
 	}
 
 	if !travSt.inTrash && !g.opts.InTrash {
@@ -285,10 +404,35 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 	return len(children) >= 1
 }
 
+// listChildren lists the children of a folder that match the mask, from the cache when offline.
+func (g *Commands) listChildren(f *File, mask int, inTrash bool, spin *playable) chan *File {
+	if g.opts.Offline {
+		return g.cachedChildren(f.Id, inTrash, g.opts.Hidden)
+	}
+
+	expr := buildExpression(f.Id, mask, inTrash)
+	if filterExpr := g.opts.Filter.query(); filterExpr != "" {
+		expr = expr + " and " + filterExpr
+	}
+
+	req := g.rem.service.Files.List()
+	req.Q(expr)
+	req.MaxResults(g.opts.PageSize)
+
+	spin.pause()
+	defer spin.play()
+
+	return reqDoPage(req, g.opts.Hidden, g.opts.canPrompt())
+}
+
 func isMinimal(mask int) bool {
 	return (mask & Minimal) != 0
 }
 
+func longFormat(mask int) bool {
+	return (mask & LongFormat) != 0
+}
+
 func owners(mask int) bool {
 	return (mask & Owners) != 0
 }
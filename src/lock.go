@@ -0,0 +1,37 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+
+	"github.com/odeke-em/drive/config"
+)
+
+var (
+	ErrContextLocked = errors.New("another drive process, such as the daemon, is modifying this context. Try again shortly")
+)
+
+// lockContext takes the context lock for the duration of a mutation
+// so that interactive commands, watch and the daemon don't collide.
+func (g *Commands) lockContext() (unlock func(), err error) {
+	f, err := lockFile(config.LockAbsPath(g.context.AbsPathOf("")))
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+	}, nil
+}
@@ -27,6 +27,19 @@ func (g *Commands) Move() (err error) {
 
 	rest, dest := g.opts.Sources[:argc-1], g.opts.Sources[argc-1]
 
+	if g.opts.DryRun {
+		g.beginPlan(MoveKey)
+	} else {
+		unlock, lErr := g.lockContext()
+		if lErr != nil {
+			return lErr
+		}
+		defer unlock()
+
+		g.beginHistory(MoveKey)
+		defer g.commitHistory()
+	}
+
 	for _, src := range rest {
 		prefix := commonPrefix(src, dest)
 
@@ -38,10 +51,13 @@ func (g *Commands) Move() (err error) {
 		err = g.move(src, dest)
 		if err != nil {
 			// TODO: Actually throw the error? Impact on UX if thrown?
-			fmt.Printf("%s: %v\n", src, err)
+			g.log.LogErrf("%s: %v\n", src, err)
 		}
 	}
 
+	if g.opts.DryRun {
+		return g.emitPlan()
+	}
 	return nil
 }
 
@@ -98,11 +114,37 @@ func (g *Commands) move(src, dest string) (err error) {
 		return fmt.Errorf("move: cannot move '%s' to itself", src)
 	}
 
+	if g.opts.DryRun {
+		g.planAdd(&planEntry{
+			Op:      PlanOpMove,
+			Path:    src,
+			Target:  newFullPath,
+			SrcId:   remSrc.Id,
+			DestId:  newParent.Id,
+			SrcSize: remSrc.Size,
+			IsDir:   remSrc.IsDir,
+		})
+		return nil
+	}
+
 	if err = g.rem.insertParent(remSrc.Id, newParent.Id); err != nil {
 		return err
 	}
 
-	return g.removeParent(remSrc.Id, src)
+	if err = g.removeParent(remSrc.Id, src); err != nil {
+		return err
+	}
+
+	if oldParent != nil {
+		g.recordInverse(&inverseOp{
+			Kind:         InverseMove,
+			Path:         newFullPath,
+			Id:           remSrc.Id,
+			FromParentId: newParent.Id,
+			ToParentId:   oldParent.Id,
+		})
+	}
+	return nil
 }
 
 func (g *Commands) removeParent(fileId, relToRootPath string) error {
@@ -149,6 +191,24 @@ func (g *Commands) Rename() error {
 		}
 	}
 
-	_, err = g.rem.rename(remSrc.Id, newName)
-	return err
+	unlock, lErr := g.lockContext()
+	if lErr != nil {
+		return lErr
+	}
+	defer unlock()
+
+	g.beginHistory(RenameKey)
+	defer g.commitHistory()
+
+	if _, err = g.rem.rename(remSrc.Id, newName, remSrc.Etag); err != nil {
+		return fmt.Errorf("%s: %v", src, err)
+	}
+
+	g.recordInverse(&inverseOp{
+		Kind:  InverseRename,
+		Path:  newFullPath,
+		Id:    remSrc.Id,
+		Title: urlToPath(remSrc.Name, false),
+	})
+	return nil
 }
@@ -0,0 +1,405 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+const (
+	PlanOpAdd         = "add"
+	PlanOpDelete      = "delete"
+	PlanOpMod         = "modify"
+	PlanOpModConflict = "conflict"
+	PlanOpTrash       = "trash"
+	PlanOpUntrash     = "untrash"
+	PlanOpMove        = "move"
+	PlanOpCopy        = "copy"
+	PlanOpRename      = "rename"
+)
+
+// planEntry is the machine readable form of a single resolved change.
+type planEntry struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	// Target is the destination path for moves, copies and renames
+	Target string `json:"target,omitempty"`
+	// Title is the remote title that a rename gives
+	Title    string `json:"title,omitempty"`
+	SrcId    string `json:"srcId,omitempty"`
+	DestId   string `json:"destId,omitempty"`
+	SrcSize  int64  `json:"srcSize"`
+	DestSize int64  `json:"destSize"`
+	IsDir    bool   `json:"isDir"`
+	// ReasonMask is the result of fileDifferences between src and dest
+	ReasonMask int      `json:"reasonMask"`
+	Reasons    []string `json:"reasons,omitempty"`
+	Conflict   bool     `json:"conflict"`
+
+	// The fields below are only needed to replay a saved plan.
+	Parent         string     `json:"parent,omitempty"`
+	Force          bool       `json:"force,omitempty"`
+	NoClobber      bool       `json:"noClobber,omitempty"`
+	IgnoreConflict bool       `json:"ignoreConflict,omitempty"`
+	IgnoreChecksum bool       `json:"ignoreChecksum,omitempty"`
+	SrcState       *fileState `json:"srcState,omitempty"`
+	DestState      *fileState `json:"destState,omitempty"`
+}
+
+// fileState is the precondition that a side of a
+// saved change must still satisfy for it to be applied.
+type fileState struct {
+	Exists  bool   `json:"exists"`
+	IsDir   bool   `json:"isDir,omitempty"`
+	Etag    string `json:"etag,omitempty"`
+	Md5     string `json:"md5,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+	ModTime int64  `json:"mtime,omitempty"`
+}
+
+type plan struct {
+	Command string       `json:"command"`
+	Exports []string     `json:"exports,omitempty"`
+	Changes []*planEntry `json:"changes"`
+}
+
+func stateOf(f *File) *fileState {
+	if f == nil {
+		return &fileState{Exists: false}
+	}
+	st := &fileState{
+		Exists: true,
+		IsDir:  f.IsDir,
+		Etag:   f.Etag,
+	}
+	if !f.IsDir {
+		st.Md5 = md5Checksum(f)
+		st.Size = f.Size
+		st.ModTime = f.ModTime.Unix()
+	}
+	return st
+}
+
+// holds reports whether f is still in the recorded state.
+func (st *fileState) holds(f *File) bool {
+	if st == nil {
+		return true
+	}
+	if !st.Exists || f == nil {
+		return !st.Exists && f == nil
+	}
+	if st.IsDir != f.IsDir {
+		return false
+	}
+	if st.Etag != "" && st.Etag != f.Etag {
+		return false
+	}
+	if st.IsDir {
+		return true
+	}
+	if st.Size != f.Size || st.ModTime != f.ModTime.Unix() {
+		return false
+	}
+	return st.Md5 == "" || st.Md5 == md5Checksum(f)
+}
+
+func (op *Operation) planOp() string {
+	switch *op {
+	case OpAdd:
+		return PlanOpAdd
+	case OpDelete:
+		return PlanOpDelete
+	case OpMod:
+		return PlanOpMod
+	case OpModConflict:
+		return PlanOpModConflict
+	case OpRename:
+		return PlanOpRename
+	}
+	return ""
+}
+
+func differenceNames(mask int) (names []string) {
+	if dirTypeDiffers(mask) {
+		names = append(names, "dirType")
+	}
+	if checksumDiffers(mask) {
+		names = append(names, "md5Checksum")
+	}
+	if modTimeDiffers(mask) {
+		names = append(names, "modTime")
+	}
+	if sizeDiffers(mask) {
+		names = append(names, "size")
+	}
+	return
+}
+
+func newPlanEntry(op string, c *Change) *planEntry {
+	entry := &planEntry{
+		Op:   op,
+		Path: c.Path,
+	}
+	if c.Src != nil {
+		entry.SrcId = c.Src.Id
+		entry.SrcSize = c.Src.Size
+		entry.IsDir = c.Src.IsDir
+	}
+	if c.Dest != nil {
+		entry.DestId = c.Dest.Id
+		entry.DestSize = c.Dest.Size
+		entry.IsDir = entry.IsDir || c.Dest.IsDir
+	}
+	if c.Src != nil && c.Dest != nil {
+		entry.ReasonMask = fileDifferences(c.Src, c.Dest, c.IgnoreChecksum)
+		entry.Reasons = differenceNames(entry.ReasonMask)
+	}
+	if c.RenameTo != "" {
+		entry.Target = c.renamedPath()
+		entry.Title = c.RenameTo
+	}
+	return entry
+}
+
+func newSavedPlanEntry(op string, c *Change) *planEntry {
+	entry := newPlanEntry(op, c)
+	entry.Parent = c.Parent
+	entry.Force = c.Force
+	entry.NoClobber = c.NoClobber
+	entry.IgnoreConflict = c.IgnoreConflict
+	entry.IgnoreChecksum = c.IgnoreChecksum
+	entry.SrcState = stateOf(c.Src)
+	entry.DestState = stateOf(c.Dest)
+	return entry
+}
+
+func (g *Commands) beginPlan(command string) {
+	g.plan = &plan{Command: command, Changes: []*planEntry{}}
+}
+
+func (g *Commands) planAdd(entry *planEntry) {
+	if g.plan != nil && entry != nil {
+		g.plan.Changes = append(g.plan.Changes, entry)
+	}
+}
+
+// emitPlan writes out the collected plan as JSON to
+// the file requested by PlanOut, otherwise to stdout.
+func (g *Commands) emitPlan() error {
+	if g.plan == nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(g.plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if g.opts.PlanOut != "" {
+		if err = ioutil.WriteFile(g.opts.PlanOut, data, 0600); err == nil {
+			g.log.Logf("Saved %d change(s) to %s\n", len(g.plan.Changes), g.opts.PlanOut)
+		}
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func readPlan(p string) (*plan, error) {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	saved := &plan{}
+	if err = json.Unmarshal(data, saved); err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	return saved, nil
+}
+
+// planChangeList emits the resolved change list, flagging the
+// changes that conflict handling would have refused to apply.
+func (g *Commands) planChangeList(command string, cl []*Change, conflictsPtr *[]*Change) error {
+	conflicting := map[*Change]bool{}
+	if conflictsPtr != nil {
+		for _, c := range *conflictsPtr {
+			conflicting[c] = true
+		}
+	}
+
+	saving := g.opts.PlanOut != ""
+
+	g.beginPlan(command)
+	if command == PullKey {
+		g.plan.Exports = g.opts.Exports
+	}
+	for _, c := range cl {
+		op := c.Op()
+		if op == OpNone {
+			continue
+		}
+		var entry *planEntry
+		if saving {
+			entry = newSavedPlanEntry(op.planOp(), c)
+		} else {
+			entry = newPlanEntry(op.planOp(), c)
+		}
+		entry.Conflict = conflicting[c]
+		g.planAdd(entry)
+	}
+	return g.emitPlan()
+}
+
+// Apply replays a plan saved by push or pull. Entries whose source or
+// destination no longer match the state recorded in the plan are refused.
+func (g *Commands) Apply(planPath string) error {
+	saved, err := readPlan(planPath)
+	if err != nil {
+		return err
+	}
+
+	isPush := false
+	switch saved.Command {
+	case PushKey:
+		isPush = true
+	case PullKey:
+	default:
+		return fmt.Errorf("%s: cannot apply a plan made by '%s'", planPath, saved.Command)
+	}
+
+	// Changes beneath the targets of renames were resolved as if they were made
+	renamed := map[string]string{}
+	for _, entry := range saved.Changes {
+		if entry.Op == PlanOpRename {
+			renamed[entry.Target] = entry.DestId
+		}
+	}
+
+	var cl []*Change
+	refused := 0
+	for _, entry := range saved.Changes {
+		c, cErr := g.changeFromPlanEntry(entry, isPush, renamed)
+		if cErr != nil {
+			g.log.LogErrf("\033[91mrefused\033[00m %s: %v\n", entry.Path, cErr)
+			refused += 1
+			continue
+		}
+		cl = append(cl, c)
+	}
+
+	if refused >= 1 {
+		g.log.LogErrf("%d of %d change(s) no longer hold and will not be applied\n", refused, len(saved.Changes))
+	}
+
+	ok, opMap := printChangeList(g.log, cl, !g.opts.canPrompt(), false)
+	if !ok {
+		return nil
+	}
+
+	unlock, err := g.lockContext()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	g.beginHistory(saved.Command)
+	defer g.commitHistory()
+
+	if isPush {
+		err = g.playPushChanges(cl, opMap)
+	} else {
+		err = g.playPullChanges(cl, saved.Exports, opMap)
+	}
+	if err == nil && refused >= 1 {
+		err = fmt.Errorf("%d change(s) were refused", refused)
+	}
+	return err
+}
+
+func (g *Commands) changeFromPlanEntry(entry *planEntry, isPush bool, renamed map[string]string) (*Change, error) {
+	if entry.Conflict {
+		return nil, fmt.Errorf("conflicting change")
+	}
+	if entry.SrcState == nil || entry.DestState == nil {
+		return nil, fmt.Errorf("no preconditions recorded")
+	}
+	if entry.Op == PlanOpRename {
+		return g.renameFromPlanEntry(entry)
+	}
+
+	local, err := g.resolveToLocalFile(entry.Path, g.context.AbsPathOf(entry.Path), isPush)
+	if err != nil {
+		return nil, err
+	}
+	var remote *File
+	if id, ok := renamed[entry.Path]; ok {
+		if remote, err = g.rem.FindById(id); err != nil {
+			return nil, err
+		}
+		remote.Name = path.Base(entry.Path)
+	} else if remote, err = g.rem.FindByPath(entry.Path); err != nil && err != ErrPathNotExists {
+		return nil, err
+	}
+
+	src, dest := remote, local
+	if isPush {
+		src, dest = local, remote
+	}
+	if !entry.SrcState.holds(src) {
+		return nil, fmt.Errorf("source changed since the plan was made")
+	}
+	if !entry.DestState.holds(dest) {
+		return nil, fmt.Errorf("destination changed since the plan was made")
+	}
+
+	c := &Change{
+		Path:           entry.Path,
+		Parent:         entry.Parent,
+		Src:            src,
+		Dest:           dest,
+		Force:          entry.Force,
+		NoClobber:      entry.NoClobber,
+		IgnoreConflict: entry.IgnoreConflict,
+		IgnoreChecksum: entry.IgnoreChecksum,
+	}
+
+	op := c.Op()
+	if resolved := op.planOp(); resolved != entry.Op {
+		return nil, fmt.Errorf("resolves to '%s' instead of '%s'", resolved, entry.Op)
+	}
+	return c, nil
+}
+
+// renameFromPlanEntry looks up the file to rename by id, as its
+// path is shared with the siblings that it clashes with.
+func (g *Commands) renameFromPlanEntry(entry *planEntry) (*Change, error) {
+	remote, err := g.rem.FindById(entry.DestId)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.DestState.holds(remote) {
+		return nil, fmt.Errorf("destination changed since the plan was made")
+	}
+	return &Change{
+		Path:     entry.Path,
+		Parent:   entry.Parent,
+		Dest:     remote,
+		RenameTo: entry.Title,
+	}, nil
+}
@@ -42,6 +42,8 @@ type downloadArg struct {
 	path            string
 	exportURL       string
 	ackByteProgress bool
+	// progress is where the downloaded bytes are reported during a play
+	progress *playProgress
 }
 
 // Pull from remote if remote path exists and in a god context. If path is a
@@ -69,6 +71,9 @@ func (g *Commands) Pull() (err error) {
 	spin.stop()
 
 	nonConflictsPtr, conflictsPtr := g.resolveConflicts(cl, false)
+	if g.opts.DryRun || g.opts.PlanOut != "" {
+		return g.planChangeList(PullKey, cl, conflictsPtr)
+	}
 	if conflictsPtr != nil {
 		warnConflictsPersist(g.log, *conflictsPtr)
 		return fmt.Errorf("conflicts have prevented a pull operation")
@@ -81,6 +86,15 @@ func (g *Commands) Pull() (err error) {
 		return
 	}
 
+	unlock, err := g.lockContext()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	g.beginHistory(PullKey)
+	defer g.commitHistory()
+
 	return g.playPullChanges(nonConflicts, g.opts.Exports, opMap)
 }
 
@@ -117,6 +131,9 @@ func (g *Commands) PullMatches() (err error) {
 	}
 
 	nonConflictsPtr, conflictsPtr := g.resolveConflicts(cl, false)
+	if g.opts.DryRun || g.opts.PlanOut != "" {
+		return g.planChangeList(PullKey, cl, conflictsPtr)
+	}
 	if conflictsPtr != nil {
 		warnConflictsPersist(g.log, *conflictsPtr)
 		return fmt.Errorf("conflicts have prevented a pull operation")
@@ -129,6 +146,15 @@ func (g *Commands) PullMatches() (err error) {
 		return nil
 	}
 
+	unlock, err := g.lockContext()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	g.beginHistory(PullKey)
+	defer g.commitHistory()
+
 	return g.playPullChanges(nonConflicts, g.opts.Exports, opMap)
 }
 
@@ -161,8 +187,6 @@ func (g *Commands) PullPiped() (err error) {
 }
 
 func (g *Commands) playPullChanges(cl []*Change, exports []string, opMap *map[Operation]sizeCounter) (err error) {
-	var next []*Change
-
 	if opMap == nil {
 		result := opChangeCount(cl)
 		opMap = &result
@@ -175,9 +199,7 @@ func (g *Commands) playPullChanges(cl []*Change, exports []string, opMap *map[Op
 		totalSize += counter.src
 	}
 
-	g.taskStart(totalSize)
-
-	defer close(g.rem.progressChan)
+	progress := g.startProgress(totalSize)
 
 	// TODO: Only provide precedence ordering if all the other options are allowed
 	// Currently noop on sorting by precedence
@@ -185,12 +207,20 @@ func (g *Commands) playPullChanges(cl []*Change, exports []string, opMap *map[Op
 		sort.Sort(ByPrecedence(cl))
 	}
 
-	go func() {
-		for n := range g.rem.progressChan {
-			g.taskAdd(int64(n))
-		}
-	}()
+	g.pullChanges(cl, exports, progress)
 
+	g.finishProgress(progress)
+	return err
+}
+
+// pullChanges applies changes locally, at most maxNumOfConcPullTasks at a time.
+func (g *Commands) pullChanges(cl []*Change, exports []string, progress *playProgress) {
+	cl, conflicts := g.renameClashes(cl)
+	if conflictsPersist(conflicts) {
+		warnRemoteModified(g.log, conflicts)
+	}
+
+	var next []*Change
 	for {
 		if len(cl) > maxNumOfConcPullTasks {
 			next, cl = cl[:maxNumOfConcPullTasks], cl[maxNumOfConcPullTasks:len(cl)]
@@ -207,23 +237,20 @@ func (g *Commands) playPullChanges(cl []*Change, exports []string, opMap *map[Op
 		for _, c := range next {
 			switch c.Op() {
 			case OpMod:
-				go g.localMod(&wg, c, exports)
+				go g.localMod(&wg, c, exports, progress)
 			case OpModConflict:
-				go g.localMod(&wg, c, exports)
+				go g.localMod(&wg, c, exports, progress)
 			case OpAdd:
-				go g.localAdd(&wg, c, exports)
+				go g.localAdd(&wg, c, exports, progress)
 			case OpDelete:
-				go g.localDelete(&wg, c)
+				go g.localDelete(&wg, c, progress)
 			}
 		}
 		wg.Wait()
 	}
-
-	g.taskFinish()
-	return err
 }
 
-func (g *Commands) localMod(wg *sync.WaitGroup, change *Change, exports []string) (err error) {
+func (g *Commands) localMod(wg *sync.WaitGroup, change *Change, exports []string, progress *playProgress) (err error) {
 	defer func() {
 		if err == nil {
 			src := change.Src
@@ -246,8 +273,12 @@ func (g *Commands) localMod(wg *sync.WaitGroup, change *Change, exports []string
 	// content yet it could just be a modTime difference
 	mask := fileDifferences(change.Src, change.Dest, change.IgnoreChecksum)
 	if checksumDiffers(mask) {
+		// Keep the old content around so that the pull can be undone
+		if err = g.stash(change.Path); err != nil {
+			return
+		}
 		// download and replace
-		if err = g.download(change, exports); err != nil {
+		if err = g.download(change, exports, progress); err != nil {
 			return
 		}
 		downloadPerformed = true
@@ -259,14 +290,14 @@ func (g *Commands) localMod(wg *sync.WaitGroup, change *Change, exports []string
 	if !downloadPerformed {
 		chunks := chunkInt64(change.Src.Size)
 		for n := range chunks {
-			g.rem.progressChan <- n
+			progress.add(n)
 		}
 	}
 
 	return
 }
 
-func (g *Commands) localAdd(wg *sync.WaitGroup, change *Change, exports []string) (err error) {
+func (g *Commands) localAdd(wg *sync.WaitGroup, change *Change, exports []string, progress *playProgress) (err error) {
 	defer func() {
 		if err == nil {
 			src := change.Src
@@ -294,11 +325,27 @@ func (g *Commands) localAdd(wg *sync.WaitGroup, change *Change, exports []string
 	}
 
 	if change.Src.IsDir {
-		return os.Mkdir(destAbsPath, os.ModeDir|0755)
+		if err = os.Mkdir(destAbsPath, os.ModeDir|0755); err == nil {
+			g.recordInverse(&inverseOp{Kind: InverseRemoveLocal, Path: change.Path})
+		}
+		return err
+	}
+
+	// A forced pull can re-add content that already exists locally
+	if change.Dest != nil && !change.Dest.IsDir {
+		if err = g.stash(change.Path); err != nil {
+			return
+		}
+	} else {
+		defer func() {
+			if err == nil {
+				g.recordInverse(&inverseOp{Kind: InverseRemoveLocal, Path: change.Path})
+			}
+		}()
 	}
 
 	// download and create
-	if err = g.download(change, exports); err != nil {
+	if err = g.download(change, exports, progress); err != nil {
 		return
 	}
 
@@ -306,16 +353,21 @@ func (g *Commands) localAdd(wg *sync.WaitGroup, change *Change, exports []string
 	return
 }
 
-func (g *Commands) localDelete(wg *sync.WaitGroup, change *Change) (err error) {
+func (g *Commands) localDelete(wg *sync.WaitGroup, change *Change, progress *playProgress) (err error) {
 	defer func() {
 		if err == nil {
 			chunks := chunkInt64(change.Dest.Size)
 			for n := range chunks {
-				g.rem.progressChan <- n
+				progress.add(n)
 			}
 		}
 		wg.Done()
 	}()
+	if g.history != nil {
+		// Stashing instead of removing keeps the pull undoable
+		err = g.stash(change.Path)
+		return
+	}
 	err = os.RemoveAll(change.Dest.BlobAt)
 	return
 }
@@ -407,7 +459,7 @@ func isLocalFile(f *File) bool {
 	return f != nil && f.Etag == ""
 }
 
-func (g *Commands) download(change *Change, exports []string) (err error) {
+func (g *Commands) download(change *Change, exports []string, progress *playProgress) (err error) {
 	if change.Src == nil {
 		return fmt.Errorf("Tried to download nil change.Src")
 	}
@@ -418,6 +470,7 @@ func (g *Commands) download(change *Change, exports []string) (err error) {
 			path:            destAbsPath,
 			id:              change.Src.Id,
 			ackByteProgress: true,
+			progress:        progress,
 		}
 
 		return g.singleDownload(&dlArg)
@@ -506,11 +559,10 @@ func (g *Commands) singleDownload(dlArg *downloadArg) (err error) {
 		commChan := ws.ProgressChan()
 		if dlArg.ackByteProgress {
 			for n := range commChan {
-				g.rem.progressChan <- n
+				dlArg.progress.add(n)
 			}
 		} else { // Just drain the progress channel
 			for _ = range commChan {
-				g.rem.progressChan <- 0
 			}
 		}
 	}()
@@ -21,7 +21,6 @@ import (
 	"os/signal"
 	gopath "path"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -78,6 +77,9 @@ func (g *Commands) Push() (err error) {
 	spin.stop()
 
 	nonConflictsPtr, conflictsPtr := g.resolveConflicts(cl, true)
+	if g.opts.DryRun || g.opts.PlanOut != "" {
+		return g.planChangeList(PushKey, cl, conflictsPtr)
+	}
 	if conflictsPtr != nil {
 		warnConflictsPersist(g.log, *conflictsPtr)
 		return fmt.Errorf("conflicts have prevented a push operation")
@@ -118,6 +120,15 @@ func (g *Commands) Push() (err error) {
 		return
 	}
 
+	unlock, err := g.lockContext()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	g.beginHistory(PushKey)
+	defer g.commitHistory()
+
 	return g.playPushChanges(nonConflicts, opMap)
 }
 
@@ -228,9 +239,7 @@ func (g *Commands) playPushChanges(cl []*Change, opMap *map[Operation]sizeCounte
 		totalSize += counter.src
 	}
 
-	g.taskStart(totalSize)
-
-	defer close(g.rem.progressChan)
+	progress := g.startProgress(totalSize)
 
 	// TODO: Only provide precedence ordering if all the other options are allowed
 	// Currently noop on sorting by precedence
@@ -238,28 +247,39 @@ func (g *Commands) playPushChanges(cl []*Change, opMap *map[Operation]sizeCounte
 		sort.Sort(ByPrecedence(cl))
 	}
 
-	go func() {
-		for n := range g.rem.progressChan {
-			g.taskAdd(int64(n))
-		}
-	}()
+	conflicts := g.pushChanges(cl, progress)
 
+	// Time to organize them according branching
+	g.finishProgress(progress)
+
+	if conflictsPersist(conflicts) {
+		warnRemoteModified(g.log, conflicts)
+		return fmt.Errorf("%d remote file(s) changed after resolving and were not overwritten", len(conflicts))
+	}
+	return err
+}
+
+// pushChanges applies changes to the remote one at a time, returning
+// those whose remote was modified after they were resolved.
+func (g *Commands) pushChanges(cl []*Change, progress *playProgress) (conflicts []*Change) {
+	cl, conflicts = g.renameClashes(cl)
 	for _, c := range cl {
+		var cErr error
 		switch c.Op() {
 		case OpMod:
-			g.remoteMod(c)
+			cErr = g.remoteMod(c, progress)
 		case OpModConflict:
-			g.remoteMod(c)
+			cErr = g.remoteMod(c, progress)
 		case OpAdd:
-			g.remoteAdd(c)
+			cErr = g.remoteAdd(c, progress)
 		case OpDelete:
-			g.remoteTrash(c)
+			cErr = g.remoteTrash(c)
+		}
+		if cErr == ErrRemoteModified {
+			conflicts = append(conflicts, c)
 		}
 	}
-
-	// Time to organize them according branching
-	g.taskFinish()
-	return err
+	return
 }
 
 func lonePush(g *Commands, parent, absPath, path string) (cl []*Change, err error) {
@@ -291,7 +311,7 @@ func (g *Commands) parentPather(absPath string) string {
 	return dir
 }
 
-func (g *Commands) remoteMod(change *Change) (err error) {
+func (g *Commands) remoteMod(change *Change, progress *playProgress) (err error) {
 	if change.Dest == nil && change.Src == nil {
 		err = fmt.Errorf("bug on: both dest and src cannot be nil")
 		g.log.LogErrln(err)
@@ -318,6 +338,8 @@ func (g *Commands) remoteMod(change *Change) (err error) {
 		dest:           change.Dest,
 		mask:           g.opts.TypeMask,
 		ignoreChecksum: g.opts.IgnoreChecksum,
+		overwrite:      g.opts.overwritesRemote(),
+		progress:       progress,
 	}
 
 	coercedMimeKey, ok := g.coercedMimeKey()
@@ -329,12 +351,22 @@ func (g *Commands) remoteMod(change *Change) (err error) {
 
 	rem, err := g.rem.UpsertByComparison(&args)
 	if err != nil {
-		g.log.LogErrf("%s: %v\n", change.Path, err)
+		// Remote edits made after resolving are reported as conflicts by the caller
+		if err != ErrRemoteModified {
+			g.log.LogErrf("%s: %v\n", change.Path, err)
+		}
 		return
 	}
 	if rem == nil {
 		return
 	}
+
+	if change.Dest == nil {
+		g.recordInverse(&inverseOp{Kind: InverseTrash, Path: change.Path, Id: rem.Id})
+	} else if prevRevId := change.Dest.HeadRevisionId; prevRevId != "" && prevRevId != rem.HeadRevisionId {
+		g.recordInverse(&inverseOp{Kind: InverseRevert, Path: change.Path, Id: rem.Id, RevisionId: prevRevId})
+	}
+
 	index := rem.ToIndex()
 	wErr := g.context.SerializeIndex(index, g.context.AbsPathOf(""))
 
@@ -345,8 +377,8 @@ func (g *Commands) remoteMod(change *Change) (err error) {
 	return
 }
 
-func (g *Commands) remoteAdd(change *Change) (err error) {
-	return g.remoteMod(change)
+func (g *Commands) remoteAdd(change *Change, progress *playProgress) (err error) {
+	return g.remoteMod(change, progress)
 }
 
 func (g *Commands) indexAbsPath(fileId string) string {
@@ -363,6 +395,7 @@ func (g *Commands) remoteUntrash(change *Change) (err error) {
 	if err != nil {
 		return
 	}
+	g.recordInverse(&inverseOp{Kind: InverseTrash, Path: change.Path, Id: target.Id})
 
 	index := target.ToIndex()
 	wErr := g.context.SerializeIndex(index, g.context.AbsPathOf(""))
@@ -394,7 +427,18 @@ func remoteRemover(g *Commands, change *Change, fn func(string) error) (err erro
 }
 
 func (g *Commands) remoteTrash(change *Change) error {
-	return remoteRemover(g, change, g.rem.Trash)
+	etag := change.Dest.Etag
+	if g.opts.overwritesRemote() {
+		etag = ""
+	}
+	trash := func(id string) error {
+		return g.rem.trashIfMatch(id, etag)
+	}
+	err := remoteRemover(g, change, trash)
+	if err == nil {
+		g.recordInverse(&inverseOp{Kind: InverseUntrash, Path: change.Path, Id: change.Dest.Id})
+	}
+	return err
 }
 
 func (g *Commands) remoteDelete(change *Change) error {
@@ -434,6 +478,8 @@ func (g *Commands) remoteMkdirAll(d string) (file *File, err error) {
 	}
 	parent, parentErr = g.rem.UpsertByComparison(&args)
 	if parentErr == nil && parent != nil {
+		g.recordInverse(&inverseOp{Kind: InverseTrash, Path: d, Id: parent.Id})
+
 		index := parent.ToIndex()
 		wErr := g.context.SerializeIndex(index, g.context.AbsPathOf(""))
 
@@ -445,11 +491,14 @@ func (g *Commands) remoteMkdirAll(d string) (file *File, err error) {
 	return parent, parentErr
 }
 
-func list(context *config.Context, p string, hidden bool, ignore *regexp.Regexp) (fileChan chan *File, err error) {
+func list(context *config.Context, p string, hidden bool, ignore *Ignorer, isPush bool) (fileChan chan *File, err error) {
 	absPath := context.AbsPathOf(p)
 	var f []os.FileInfo
 	f, err = ioutil.ReadDir(absPath)
 	fileChan = make(chan *File)
+	if err == nil {
+		err = ignore.load(p)
+	}
 	if err != nil {
 		close(fileChan)
 		return
@@ -460,16 +509,17 @@ func list(context *config.Context, p string, hidden bool, ignore *regexp.Regexp)
 			if file.Name() == config.GDDirSuffix {
 				continue
 			}
-			if ignore != nil && ignore.Match([]byte(file.Name())) {
-				continue
-			}
 			if isHidden(file.Name(), hidden) {
 				continue
 			}
 
+			relToRoot := gopath.Join("/", p, file.Name())
 			symlink := (file.Mode() & os.ModeSymlink) != 0
 
 			if !symlink {
+				if ignore.matchEntry(relToRoot, file.IsDir(), isPush) {
+					continue
+				}
 				resPath := gopath.Join(absPath, file.Name())
 				fileChan <- NewLocalFile(resPath, file)
 			} else {
@@ -485,6 +535,9 @@ func list(context *config.Context, p string, hidden bool, ignore *regexp.Regexp)
 				if err != nil {
 					continue
 				}
+				if ignore.matchEntry(relToRoot, symInfo.IsDir(), isPush) {
+					continue
+				}
 
 				lf := NewLocalFile(symResolvPath, symInfo)
 				// Retain the original name as appeared in
@@ -23,11 +23,13 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"code.google.com/p/goauth2/oauth"
 	"github.com/odeke-em/drive/config"
 	drive "github.com/odeke-em/google-api-go-client/drive/v2"
+	"github.com/odeke-em/google-api-go-client/googleapi"
 	"github.com/odeke-em/statos"
 )
 
@@ -56,12 +58,13 @@ const (
 	OptUpdateViewedDate
 	OptContentAsIndexableText
 	OptPinned
-	OptNewRevision
+	OptKeepHeadRevision
 )
 
 var (
-	ErrPathNotExists = errors.New("remote path doesn't exist")
-	ErrNetLookup     = errors.New("net lookup failed")
+	ErrPathNotExists  = errors.New("remote path doesn't exist")
+	ErrNetLookup      = errors.New("net lookup failed")
+	ErrRemoteModified = errors.New("remote was modified after changes were resolved")
 )
 
 var (
@@ -70,19 +73,26 @@ var (
 )
 
 type Remote struct {
-	transport    *oauth.Transport
-	service      *drive.Service
-	progressChan chan int
+	transport *oauth.Transport
+	service   *drive.Service
+
+	// cacheRoot is the context whose offline cache is loaded on first use
+	cacheRoot string
+	cacheOnce sync.Once
+	cache     *metaCache
+	cacheErr  error
+	// cachedLookups is only set by read-only commands, a stale
+	// cache must never resolve a path that is then modified
+	cachedLookups bool
 }
 
 func NewRemoteContext(context *config.Context) *Remote {
 	transport := newTransport(context)
 	service, _ := drive.New(transport.Client())
-	progressChan := make(chan int)
 	return &Remote{
-		progressChan: progressChan,
-		service:      service,
-		transport:    transport,
+		service:   service,
+		transport: transport,
+		cacheRoot: context.AbsPath,
 	}
 }
 
@@ -140,6 +150,32 @@ func buildExpression(parentId string, typeMask int, inTrash bool) string {
 	return strings.Join(exprBuilder, " and ")
 }
 
+// pathOf walks up the parents of a file to find its path relative to the
+// root. Resolved folder paths are memoized in cache by their id.
+func (r *Remote) pathOf(f *drive.File, cache map[string]string) (string, error) {
+	if len(f.Parents) < 1 {
+		return "", fmt.Errorf("%s has no parents, not in your drive", strconv.Quote(f.Title))
+	}
+	name := urlToPath(f.Title, true)
+	parent := f.Parents[0]
+	if parent.IsRoot {
+		return "/" + name, nil
+	}
+
+	parentPath, ok := cache[parent.Id]
+	if !ok {
+		parentFile, err := r.service.Files.Get(parent.Id).Do()
+		if err != nil {
+			return "", err
+		}
+		if parentPath, err = r.pathOf(parentFile, cache); err != nil {
+			return "", err
+		}
+		cache[parent.Id] = parentPath
+	}
+	return parentPath + "/" + name, nil
+}
+
 func (r *Remote) change(changeId string) (*drive.Change, error) {
 	return r.service.Changes.Get(changeId).Do()
 }
@@ -171,6 +207,11 @@ func (r *Remote) findByPath(p string, trashed bool) (*File, error) {
 	if rootLike(p) {
 		return r.FindById("root")
 	}
+	if !trashed && r.cachedLookups {
+		if f, ok := r.findByPathCached(p); ok {
+			return f, nil
+		}
+	}
 	parts := strings.Split(p, "/")
 	finder := r.findByPathRecv
 	if trashed {
@@ -239,11 +280,33 @@ func (r *Remote) EmptyTrash() error {
 	return r.service.Files.EmptyTrash().Do()
 }
 
-func (r *Remote) Trash(id string) error {
-	_, err := r.service.Files.Trash(id).Do()
+// ifMatch makes a request conditional on the remote
+// still having the etag that it was resolved with.
+func ifMatch(header http.Header, etag string) {
+	if etag != "" {
+		header.Set("If-Match", etag)
+	}
+}
+
+// preconditionErr maps the failure of an If-Match request to ErrRemoteModified.
+func preconditionErr(err error) error {
+	if gErr, ok := err.(*googleapi.Error); ok && gErr.Code == http.StatusPreconditionFailed {
+		return ErrRemoteModified
+	}
 	return err
 }
 
+func (r *Remote) Trash(id string) error {
+	return r.trashIfMatch(id, "")
+}
+
+func (r *Remote) trashIfMatch(id, etag string) error {
+	req := r.service.Files.Trash(id)
+	ifMatch(req.Header(), etag)
+	_, err := req.Do()
+	return preconditionErr(err)
+}
+
 func (r *Remote) Untrash(id string) error {
 	_, err := r.service.Files.Untrash(id).Do()
 	return err
@@ -360,6 +423,10 @@ func pin(mask int) bool {
 	return (mask & OptPinned) != 0
 }
 
+func keepHeadRevision(mask int) bool {
+	return (mask & OptKeepHeadRevision) != 0
+}
+
 func indexContent(mask int) bool {
 	return (mask & OptContentAsIndexableText) != 0
 }
@@ -373,6 +440,10 @@ type upsertOpt struct {
 	ignoreChecksum bool
 	mimeKey        string
 	nonStatable    bool
+	// overwrite skips the etag precondition on updates
+	overwrite bool
+	// progress is where the uploaded bytes are reported during a play
+	progress *playProgress
 }
 
 func togglePropertiesInsertCall(req *drive.FilesInsertCall, mask int) *drive.FilesInsertCall {
@@ -403,6 +474,9 @@ func togglePropertiesUpdateCall(req *drive.FilesUpdateCall, mask int) *drive.Fil
 	if pin(mask) {
 		req = req.Pinned(true)
 	}
+	if keepHeadRevision(mask) {
+		req = req.NewRevision(false)
+	}
 	if indexContent(mask) {
 		req = req.UseContentAsIndexableText(true)
 	}
@@ -452,6 +526,11 @@ func (r *Remote) upsertByComparison(body io.Reader, args *upsertOpt) (f *File, m
 	// We always want it to match up with the local time
 	req.SetModifiedDate(true)
 
+	// Refuse to clobber edits made after the remote was resolved
+	if args.dest != nil && !isLocalFile(args.dest) && !args.overwrite {
+		ifMatch(req.Header(), args.dest.Etag)
+	}
+
 	if !args.src.IsDir {
 		if args.dest == nil || args.nonStatable {
 			req = req.Media(body)
@@ -466,21 +545,23 @@ func (r *Remote) upsertByComparison(body io.Reader, args *upsertOpt) (f *File, m
 	req = togglePropertiesUpdateCall(req, args.mask)
 
 	if uploaded, err = req.Do(); err != nil {
+		err = preconditionErr(err)
 		return
 	}
 	f = NewRemoteFile(uploaded)
 	return
 }
 
-func (r *Remote) rename(fileId, newTitle string) (*File, error) {
+func (r *Remote) rename(fileId, newTitle, etag string) (*File, error) {
 	f := &drive.File{
 		Title: newTitle,
 	}
 
 	req := r.service.Files.Update(fileId, f)
+	ifMatch(req.Header(), etag)
 	uploaded, err := req.Do()
 	if err != nil {
-		return nil, err
+		return nil, preconditionErr(err)
 	}
 
 	return NewRemoteFile(uploaded), nil
@@ -511,6 +592,32 @@ func (r *Remote) copy(newName, parentId string, srcFile *File) (*File, error) {
 	return NewRemoteFile(copied), nil
 }
 
+func (r *Remote) revisionContent(fileId, revId string) (io.ReadCloser, error) {
+	rev, err := r.revision(fileId, revId)
+	if err != nil {
+		return nil, err
+	}
+	if rev.DownloadUrl == "" {
+		return nil, fmt.Errorf("revision %s of %s has no downloadable content", revId, fileId)
+	}
+	return r.Download(fileId, rev.DownloadUrl)
+}
+
+// restoreRevision makes the content of revision revId the current content of the file.
+func (r *Remote) restoreRevision(fileId, revId string) (*File, error) {
+	body, err := r.revisionContent(fileId, revId)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	restored, err := r.service.Files.Update(fileId, &drive.File{}).Media(body).Do()
+	if err != nil {
+		return nil, err
+	}
+	return NewRemoteFile(restored), nil
+}
+
 func (r *Remote) UpsertByComparison(args *upsertOpt) (f *File, err error) {
 	var body io.Reader
 	body, err = os.Open(args.fsAbsPath)
@@ -526,7 +633,7 @@ func (r *Remote) UpsertByComparison(args *upsertOpt) (f *File, err error) {
 	go func() {
 		commChan := bd.ProgressChan()
 		for n := range commChan {
-			r.progressChan <- n
+			args.progress.add(n)
 		}
 	}()
 
@@ -538,7 +645,7 @@ func (r *Remote) UpsertByComparison(args *upsertOpt) (f *File, err error) {
 	if !mediaInserted && args.dest != nil {
 		chunks := chunkInt64(args.dest.Size)
 		for n := range chunks {
-			r.progressChan <- n
+			args.progress.add(n)
 		}
 	}
 
@@ -596,6 +703,41 @@ func (r *Remote) FindMatches(dirPath string, keywords []string, inTrash bool) (c
 	return reqDoPage(req, true, false), nil
 }
 
+// search pages through the files matching expr. Each is keyed by its path
+// relative to the root, or just its name if it is outside of your drive
+// e.g shared with you. Folder paths are memoized in pathCache by their id.
+func (r *Remote) search(expr string, hidden bool, pathCache map[string]string) chan *keyValue {
+	req := r.service.Files.List()
+	req.Q(expr)
+
+	kvChan := make(chan *keyValue)
+	go func() {
+		defer close(kvChan)
+		for {
+			results, err := req.Do()
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			for _, f := range results.Items {
+				if isHidden(f.Title, hidden) {
+					continue
+				}
+				p, pErr := r.pathOf(f, pathCache)
+				if pErr != nil {
+					p = urlToPath(f.Title, true)
+				}
+				kvChan <- &keyValue{key: p, value: NewRemoteFile(f)}
+			}
+			if results.NextPageToken == "" {
+				return
+			}
+			req = req.PageToken(results.NextPageToken)
+		}
+	}()
+	return kvChan
+}
+
 func (r *Remote) findChildren(parentId string, trashed bool) chan *File {
 	req := r.service.Files.List()
 	req.Q(fmt.Sprintf("%s in parents and trashed=%v", strconv.Quote(parentId), trashed))
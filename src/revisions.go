@@ -0,0 +1,244 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	drive "github.com/odeke-em/google-api-go-client/drive/v2"
+)
+
+const (
+	RevisionsList    = "list"
+	RevisionsGet     = "get"
+	RevisionsRestore = "restore"
+	RevisionsPin     = "pin"
+	RevisionsUnpin   = "unpin"
+)
+
+const revisionURL = "https://www.googleapis.com/drive/v2/files/%s/revisions/%s"
+
+// Google Docs are exported to this format unless others are requested.
+const defaultRevisionExport = "pdf"
+
+func (r *Remote) revisions(fileId string) ([]*drive.Revision, error) {
+	list, err := r.service.Revisions.List(fileId).Do()
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (r *Remote) revision(fileId, revId string) (*drive.Revision, error) {
+	return r.service.Revisions.Get(fileId, revId).Do()
+}
+
+// pinRevision sets whether a revision is kept forever. The request is
+// made by hand since the client library leaves out a false pinned.
+func (r *Remote) pinRevision(fileId, revId string, pinned bool) error {
+	body, err := json.Marshal(map[string]bool{"pinned": pinned})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("PATCH", fmt.Sprintf(revisionURL, fileId, revId), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.transport.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("revision %s: %s", revId, resp.Status)
+	}
+	return nil
+}
+
+func revisionModTime(rev *drive.Revision) time.Time {
+	mtime, _ := time.Parse("2006-01-02T15:04:05.000Z", rev.ModifiedDate)
+	return mtime.Round(time.Second)
+}
+
+func revisionUser(rev *drive.Revision) string {
+	if rev.LastModifyingUser != nil && rev.LastModifyingUser.EmailAddress != "" {
+		return rev.LastModifyingUser.EmailAddress
+	}
+	return rev.LastModifyingUserName
+}
+
+func (g *Commands) prettyRevision(rev *drive.Revision, head bool) {
+	marker := " "
+	if head {
+		marker = "*"
+	}
+	size, md5Checksum := "-", rev.Md5Checksum
+	if rev.DownloadUrl != "" {
+		size = prettyBytes(rev.FileSize)
+	}
+	if md5Checksum == "" {
+		md5Checksum = "-"
+	}
+	pinned := ""
+	if rev.Pinned {
+		pinned = "pinned"
+	}
+	g.log.Logf("%s %-30s %-10s %-32s %-25v %-6s %s\n", marker, rev.Id, size, md5Checksum, revisionModTime(rev), pinned, revisionUser(rev))
+}
+
+// revisionFile resolves the file whose revisions are requested.
+func (g *Commands) revisionFile() (*File, error) {
+	if len(g.opts.Sources) < 1 {
+		return nil, fmt.Errorf("revisions: expecting a remote path")
+	}
+	p := g.opts.Sources[0]
+	f, err := g.rem.FindByPath(p)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	if f.IsDir {
+		return nil, fmt.Errorf("%s: folders have no revisions", p)
+	}
+	return f, nil
+}
+
+// Revisions lists the revisions of a remote file, downloads revision revId
+// of it, makes revId its current content or pins and unpins revId, the
+// head revision by default, so that it is kept forever.
+func (g *Commands) Revisions(action, revId string) error {
+	switch action {
+	case RevisionsList, RevisionsPin, RevisionsUnpin:
+	case RevisionsGet, RevisionsRestore:
+		if revId == "" {
+			return fmt.Errorf("revisions %s: expecting <path> <revisionId>", action)
+		}
+	default:
+		return fmt.Errorf("revisions: unknown action '%s', expecting one of %s, %s, %s, %s or %s",
+			action, RevisionsList, RevisionsGet, RevisionsRestore, RevisionsPin, RevisionsUnpin)
+	}
+
+	f, err := g.revisionFile()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case RevisionsGet:
+		return g.getRevision(f, revId)
+	case RevisionsRestore:
+		return g.restoreRevision(f, revId)
+	case RevisionsPin, RevisionsUnpin:
+		if revId == "" {
+			revId = f.HeadRevisionId
+		}
+		pinned := action == RevisionsPin
+		if err = g.rem.pinRevision(f.Id, revId, pinned); err != nil {
+			return fmt.Errorf("%s: %v", g.opts.Sources[0], err)
+		}
+		if pinned {
+			g.log.Logf("Revision %s of %s will be kept forever\n", revId, g.opts.Sources[0])
+		} else {
+			g.log.Logf("Revision %s of %s may be purged again\n", revId, g.opts.Sources[0])
+		}
+		return nil
+	}
+
+	revs, err := g.rem.revisions(f.Id)
+	if err != nil {
+		return err
+	}
+	for _, rev := range revs {
+		g.prettyRevision(rev, rev.Id == f.HeadRevisionId)
+	}
+	return nil
+}
+
+// getRevision downloads a revision, exporting it for Google Docs. It is
+// saved to Out or, by default, to the file's name suffixed by the revision.
+func (g *Commands) getRevision(f *File, revId string) error {
+	rev, err := g.rem.revision(f.Id, revId)
+	if err != nil {
+		return err
+	}
+
+	url, ext := rev.DownloadUrl, filepath.Ext(f.Name)
+	if url == "" {
+		exports := g.opts.Exports
+		if len(exports) < 1 {
+			exports = []string{defaultRevisionExport}
+		}
+		for _, export := range exports {
+			if exportURL, ok := rev.ExportLinks[mimeTypeFromExt(export)]; ok {
+				url, ext = exportURL, "."+export
+				break
+			}
+		}
+		if url == "" {
+			return fmt.Errorf("revision %s of %s can't be exported to %s", revId, f.Name, strings.Join(exports, ", "))
+		}
+	}
+
+	out := g.opts.Out
+	if out == "" {
+		out = fmt.Sprintf("%s_%s%s", strings.TrimSuffix(f.Name, filepath.Ext(f.Name)), revId, ext)
+	}
+
+	dlArg := downloadArg{
+		path:      out,
+		id:        f.Id,
+		exportURL: url,
+	}
+	if err = g.singleDownload(&dlArg); err != nil {
+		return err
+	}
+	g.log.Logf("Saved revision %s of %s to %s\n", revId, g.opts.Sources[0], out)
+	return nil
+}
+
+// restoreRevision uploads a revision as the current content of the file,
+// the previous head revision is recorded so that undo can bring it back.
+func (g *Commands) restoreRevision(f *File, revId string) error {
+	if hasExportLinks(f) {
+		return fmt.Errorf("%s: revisions of Google Docs can only be restored from the web interface", f.Name)
+	}
+
+	unlock, err := g.lockContext()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	g.beginHistory(RevisionsKey)
+	defer g.commitHistory()
+
+	p := g.opts.Sources[0]
+	restored, err := g.rem.restoreRevision(f.Id, revId)
+	if err != nil {
+		return fmt.Errorf("%s: %v", p, err)
+	}
+	if f.HeadRevisionId != "" && f.HeadRevisionId != restored.HeadRevisionId {
+		g.recordInverse(&inverseOp{Kind: InverseRevert, Path: p, Id: f.Id, RevisionId: f.HeadRevisionId})
+	}
+	g.log.Logf("Restored %s to revision %s, pull to update your local copy\n", p, revId)
+	return nil
+}
@@ -0,0 +1,494 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	gopath "path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	drive "github.com/odeke-em/google-api-go-client/drive/v2"
+)
+
+// searchParentsPerQuery bounds the number of `in parents` clauses
+// per request when searching across the descendants of a folder.
+const searchParentsPerQuery = 50
+
+var searchDateTerm = regexp.MustCompile(`^(modified|viewed)(>=|<=|>|<)(.+)$`)
+
+var searchDateFields = map[string]string{
+	"modified": "modifiedDate",
+	"viewed":   "lastViewedByMeDate",
+}
+
+// tokenizeSearch splits a query on whitespace, keeping
+// double quoted phrases together and dropping their quotes.
+func tokenizeSearch(query string) (tokens []string, err error) {
+	var cur []rune
+	inQuote, started := false, false
+	for _, c := range query {
+		switch {
+		case c == '"':
+			inQuote, started = !inQuote, true
+		case !inQuote && (c == ' ' || c == '\t' || c == '\n'):
+			if started {
+				tokens = append(tokens, string(cur))
+			}
+			cur, started = cur[:0], false
+		default:
+			cur, started = append(cur, c), true
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote in %q", query)
+	}
+	if started {
+		tokens = append(tokens, string(cur))
+	}
+	return tokens, nil
+}
+
+// searchClause is a term of a search, both as a Drive query expression
+// and as a predicate evaluated against cached metadata when offline.
+type searchClause struct {
+	expr  string
+	match func(f *drive.File) bool
+}
+
+// searchGroup is an alternative of a query, clauses that are and-ed
+// together. Trashed files only match the groups that ask for them.
+type searchGroup struct {
+	clauses []*searchClause
+	trashed bool
+}
+
+// searchQuery is a disjunction of groups.
+type searchQuery struct {
+	groups []*searchGroup
+}
+
+// parseSearchQuery parses the search syntax where terms are and-ed
+// together, OR separates alternatives and a leading '-' negates a term.
+// Trashed files are left out of each alternative unless it asks for them.
+func parseSearchQuery(query string) (*searchQuery, error) {
+	tokens, err := tokenizeSearch(query)
+	if err != nil {
+		return nil, err
+	}
+
+	sq := &searchQuery{}
+	group := &searchGroup{}
+	closeGroup := func() error {
+		if len(group.clauses) < 1 {
+			return fmt.Errorf("OR expects a term on either side")
+		}
+		sq.groups = append(sq.groups, group)
+		group = &searchGroup{}
+		return nil
+	}
+
+	for _, token := range tokens {
+		if token == "OR" {
+			if err = closeGroup(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		clause, trashed, tErr := searchTerm(token)
+		if tErr != nil {
+			return nil, tErr
+		}
+		group.trashed = group.trashed || trashed
+		group.clauses = append(group.clauses, clause)
+	}
+	if len(group.clauses) >= 1 || len(sq.groups) >= 1 {
+		if err = closeGroup(); err != nil {
+			return nil, err
+		}
+	}
+	return sq, nil
+}
+
+// expr translates the query into a Drive query expression.
+func (sq *searchQuery) expr() string {
+	if len(sq.groups) < 1 {
+		return "trashed=false"
+	}
+
+	var groups []string
+	for _, group := range sq.groups {
+		var exprs []string
+		for _, clause := range group.clauses {
+			exprs = append(exprs, clause.expr)
+		}
+		if !group.trashed {
+			exprs = append(exprs, "trashed=false")
+		}
+		joined := strings.Join(exprs, " and ")
+		if len(sq.groups) >= 2 {
+			joined = "(" + joined + ")"
+		}
+		groups = append(groups, joined)
+	}
+	return strings.Join(groups, " or ")
+}
+
+// matches evaluates the query against cached metadata.
+func (sq *searchQuery) matches(f *drive.File) bool {
+	if len(sq.groups) < 1 {
+		return !isTrashed(f)
+	}
+	for _, group := range sq.groups {
+		if group.matches(f) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sg *searchGroup) matches(f *drive.File) bool {
+	if !sg.trashed && isTrashed(f) {
+		return false
+	}
+	for _, clause := range sg.clauses {
+		if !clause.match(f) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func searchTerm(token string) (clause *searchClause, trashed bool, err error) {
+	negate := strings.HasPrefix(token, "-") && len(token) > 1
+	if negate {
+		token = token[1:]
+	}
+	// term negates expressions that Drive allows prefixing with not
+	term := func(expr string, match func(f *drive.File) bool) *searchClause {
+		if !negate {
+			return &searchClause{expr: expr, match: match}
+		}
+		return &searchClause{
+			expr:  "not " + expr,
+			match: func(f *drive.File) bool { return !match(f) },
+		}
+	}
+
+	if m := searchDateTerm.FindStringSubmatch(token); m != nil {
+		t, tErr := parseFilterTime(m[3])
+		if tErr != nil {
+			return nil, false, tErr
+		}
+		op := m[2]
+		if negate {
+			op = map[string]string{">": "<=", ">=": "<", "<": ">=", "<=": ">"}[op]
+		}
+		field := m[1]
+		return &searchClause{
+			expr: fmt.Sprintf("%s %s '%s'", searchDateFields[field], op, t.UTC().Format(time.RFC3339)),
+			match: func(f *drive.File) bool {
+				date := f.ModifiedDate
+				if field == "viewed" {
+					date = f.LastViewedByMeDate
+				}
+				ft, pErr := time.Parse(time.RFC3339, date)
+				if pErr != nil {
+					return false
+				}
+				switch op {
+				case ">":
+					return ft.After(t)
+				case ">=":
+					return !ft.Before(t)
+				case "<":
+					return ft.Before(t)
+				}
+				return !ft.After(t)
+			},
+		}, false, nil
+	}
+
+	i := strings.Index(token, ":")
+	if i < 0 {
+		// Offline, the full text is approximated by the title and description
+		return term(fmt.Sprintf("fullText contains %s", strconv.Quote(token)), func(f *drive.File) bool {
+			return containsFold(f.Title, token) || containsFold(f.Description, token)
+		}), false, nil
+	}
+	key, value := token[:i], token[i+1:]
+	if value == "" {
+		return nil, false, fmt.Errorf("search: %q expects a value", key)
+	}
+
+	switch key {
+	case "title":
+		return term(fmt.Sprintf("title contains %s", strconv.Quote(value)), func(f *drive.File) bool {
+			return containsFold(f.Title, value)
+		}), false, nil
+	case "mime", "type":
+		mimeType := resolveMimeType(value)
+		if strings.HasSuffix(mimeType, "/*") {
+			prefix := strings.TrimSuffix(mimeType, "*")
+			return term(fmt.Sprintf("mimeType contains %s", strconv.Quote(prefix)), func(f *drive.File) bool {
+				return strings.HasPrefix(f.MimeType, prefix)
+			}), false, nil
+		}
+		return mimeClause(mimeType, negate), false, nil
+	case "owner":
+		return term(fmt.Sprintf("%s in owners", strconv.Quote(value)), func(f *drive.File) bool {
+			for _, owner := range f.Owners {
+				if (value == "me" && owner.IsAuthenticatedUser) || strings.EqualFold(owner.EmailAddress, value) {
+					return true
+				}
+			}
+			return false
+		}), false, nil
+	case "writer", "reader":
+		// Offline, only the permissions that came along with the cached metadata are known
+		return term(fmt.Sprintf("%s in %ss", strconv.Quote(value), key), func(f *drive.File) bool {
+			for _, perm := range f.Permissions {
+				if !strings.EqualFold(perm.EmailAddress, value) {
+					continue
+				}
+				if key == "reader" || perm.Role == "writer" || perm.Role == "owner" {
+					return true
+				}
+			}
+			return false
+		}), false, nil
+	case "is":
+		switch value {
+		case "starred":
+			return &searchClause{
+				expr:  fmt.Sprintf("starred=%v", !negate),
+				match: func(f *drive.File) bool { return (f.Labels != nil && f.Labels.Starred) != negate },
+			}, false, nil
+		case "shared":
+			return term("sharedWithMe", func(f *drive.File) bool {
+				return f.SharedWithMeDate != ""
+			}), false, nil
+		case "trashed":
+			if negate {
+				return nil, false, fmt.Errorf("search: trashed files are left out unless asked for with is:trashed")
+			}
+			return &searchClause{expr: "trashed=true", match: isTrashed}, true, nil
+		case "folder", "file":
+			return mimeClause(DriveFolderMimeType, (value == "file") != negate), false, nil
+		}
+		return nil, false, fmt.Errorf("search: unknown is:%s, expecting one of starred, shared, trashed, folder or file", value)
+	}
+	return nil, false, fmt.Errorf("search: unknown key %q in %q", key, token)
+}
+
+func mimeClause(mimeType string, negate bool) *searchClause {
+	op := "="
+	if negate {
+		op = "!="
+	}
+	return &searchClause{
+		expr:  fmt.Sprintf("mimeType %s %s", op, strconv.Quote(mimeType)),
+		match: func(f *drive.File) bool { return (f.MimeType == mimeType) != negate },
+	}
+}
+
+// Search lists the files matching a query, either across your whole
+// drive or within the source folders, and if recursive their descendants.
+func (g *Commands) Search(query string) error {
+	g.rem.allowCachedLookups()
+	sq, err := parseSearchQuery(query)
+	if err != nil {
+		return err
+	}
+	if (g.opts.TypeMask & Folder) != 0 {
+		sq.and(mimeClause(DriveFolderMimeType, false))
+	} else if (g.opts.TypeMask & NonFolder) != 0 {
+		sq.and(mimeClause(DriveFolderMimeType, true))
+	}
+
+	pathCache := map[string]string{}
+	var parentIds []string
+	if len(g.opts.Sources) >= 1 {
+		if parentIds, err = g.searchParents(pathCache); err != nil {
+			return err
+		}
+	}
+
+	var results chan *keyValue
+	if g.opts.Offline {
+		if results, err = g.searchCached(sq, parentIds); err != nil {
+			return err
+		}
+	} else {
+		results = g.searchRemote(sq, parentIds, pathCache)
+	}
+
+	spin := g.playabler()
+	spin.play()
+	defer spin.stop()
+
+	seen := map[string]bool{}
+	for kv := range results {
+		f := kv.value.(*File)
+		if seen[f.Id] {
+			continue
+		}
+		seen[f.Id] = true
+
+		parent := gopath.Dir(kv.key)
+		if rootLike(parent) || parent == "." {
+			parent = ""
+		}
+		g.printFile(f, attribute{
+			minimal: isMinimal(g.opts.TypeMask),
+			mask:    g.opts.TypeMask,
+			parent:  parent,
+		})
+	}
+
+	if len(seen) < 1 {
+		g.log.LogErrln("no matches found!")
+	}
+	return nil
+}
+
+// and and-s a clause to every alternative of the query.
+func (sq *searchQuery) and(clause *searchClause) {
+	if len(sq.groups) < 1 {
+		sq.groups = []*searchGroup{{}}
+	}
+	for _, group := range sq.groups {
+		group.clauses = append(group.clauses, clause)
+	}
+}
+
+// searchRemote runs the query, restricted to the parents if any
+// are given, a bounded number of them per request.
+func (g *Commands) searchRemote(sq *searchQuery, parentIds []string, pathCache map[string]string) chan *keyValue {
+	expr := sq.expr()
+	var exprs []string
+	if len(parentIds) < 1 {
+		exprs = append(exprs, expr)
+	}
+	for i := 0; i < len(parentIds); i += searchParentsPerQuery {
+		end := i + searchParentsPerQuery
+		if end > len(parentIds) {
+			end = len(parentIds)
+		}
+		var inParents []string
+		for _, id := range parentIds[i:end] {
+			inParents = append(inParents, fmt.Sprintf("%s in parents", strconv.Quote(id)))
+		}
+		exprs = append(exprs, fmt.Sprintf("(%s) and %s", strings.Join(inParents, " or "), expr))
+	}
+
+	kvChan := make(chan *keyValue)
+	go func() {
+		defer close(kvChan)
+		for _, e := range exprs {
+			for kv := range g.rem.search(e, g.opts.Hidden, pathCache) {
+				kvChan <- kv
+			}
+		}
+	}()
+	return kvChan
+}
+
+// searchCached evaluates the query against the offline cache.
+func (g *Commands) searchCached(sq *searchQuery, parentIds []string) (chan *keyValue, error) {
+	mc, err := g.offlineCache()
+	if err != nil {
+		return nil, err
+	}
+	inParents := map[string]bool{}
+	for _, id := range parentIds {
+		inParents[id] = true
+	}
+
+	kvChan := make(chan *keyValue)
+	go func() {
+		defer close(kvChan)
+		for _, f := range mc.Files {
+			if isHidden(f.Title, g.opts.Hidden) || !sq.matches(f) {
+				continue
+			}
+			if len(parentIds) >= 1 {
+				under := false
+				for _, parent := range f.Parents {
+					under = under || inParents[parent.Id]
+				}
+				if !under {
+					continue
+				}
+			}
+			p, pErr := mc.pathOf(f)
+			if pErr != nil {
+				p = urlToPath(f.Title, true)
+			}
+			kvChan <- &keyValue{key: p, value: NewRemoteFile(f)}
+		}
+	}()
+	return kvChan, nil
+}
+
+// searchParents returns the ids of the source folders and, when
+// recursive, of all the folders beneath them whose paths are memoized.
+func (g *Commands) searchParents(pathCache map[string]string) (ids []string, err error) {
+	type folder struct {
+		id, path string
+	}
+
+	var queue []folder
+	for _, relToRoot := range g.opts.Sources {
+		r, rErr := g.findByPath(relToRoot, false)
+		if rErr != nil {
+			return nil, fmt.Errorf("%s: %v", relToRoot, rErr)
+		}
+		if !r.IsDir {
+			return nil, fmt.Errorf("%s: not a folder", relToRoot)
+		}
+		queue = append(queue, folder{id: r.Id, path: relToRoot})
+	}
+
+	for len(queue) >= 1 {
+		cur := queue[0]
+		queue = queue[1:]
+		ids = append(ids, cur.id)
+		if !rootLike(cur.path) {
+			pathCache[cur.id] = cur.path
+		}
+		if !g.opts.Recursive {
+			continue
+		}
+		var children chan *File
+		if g.opts.Offline {
+			children = g.cachedChildren(cur.id, false, true)
+		} else {
+			children = g.rem.findChildren(cur.id, false)
+		}
+		for child := range children {
+			if child.IsDir && !isHidden(child.Name, g.opts.Hidden) {
+				queue = append(queue, folder{id: child.Id, path: gopath.Join(cur.path, child.Name)})
+			}
+		}
+	}
+	return ids, nil
+}
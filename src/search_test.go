@@ -0,0 +1,154 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"testing"
+
+	drive "github.com/odeke-em/google-api-go-client/drive/v2"
+)
+
+func TestTokenizeSearch(t *testing.T) {
+	cases := []struct {
+		query string
+		want  []string
+		fails bool
+	}{
+		{query: "", want: nil},
+		{query: "   ", want: nil},
+		{query: "a b", want: []string{"a", "b"}},
+		{query: " a\tb\nc ", want: []string{"a", "b", "c"}},
+		{query: `"annual report" pdf`, want: []string{"annual report", "pdf"}},
+		{query: `title:"my notes"`, want: []string{"title:my notes"}},
+		{query: `""`, want: []string{""}},
+		{query: `a OR b`, want: []string{"a", "OR", "b"}},
+		{query: `"unterminated`, fails: true},
+	}
+
+	for _, tc := range cases {
+		got, err := tokenizeSearch(tc.query)
+		if tc.fails {
+			if err == nil {
+				t.Errorf("tokenizeSearch(%q) = %q, expected an error", tc.query, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("tokenizeSearch(%q): unexpected error %v", tc.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("tokenizeSearch(%q) = %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	cases := []struct {
+		query string
+		want  string
+		fails bool
+	}{
+		{query: "", want: "trashed=false"},
+		{query: "report", want: `fullText contains "report" and trashed=false`},
+		{query: "-report", want: `not fullText contains "report" and trashed=false`},
+		{query: "title:a mime:pdf", want: `title contains "a" and mimeType = "application/pdf" and trashed=false`},
+		{query: "mime:image/*", want: `mimeType contains "image/" and trashed=false`},
+		{query: "is:folder", want: `mimeType = "application/vnd.google-apps.folder" and trashed=false`},
+		{query: "-is:folder", want: `mimeType != "application/vnd.google-apps.folder" and trashed=false`},
+		{query: "is:starred", want: "starred=true and trashed=false"},
+		{query: "-is:starred", want: "starred=false and trashed=false"},
+		{query: "is:trashed", want: "trashed=true"},
+		{query: "owner:me", want: `"me" in owners and trashed=false`},
+		{query: "modified>2015-06-01T00:00:00Z", want: "modifiedDate > '2015-06-01T00:00:00Z' and trashed=false"},
+		{query: "-viewed<2015-06-01T00:00:00Z", want: "lastViewedByMeDate >= '2015-06-01T00:00:00Z' and trashed=false"},
+		{
+			query: "title:a OR title:b",
+			want:  `(title contains "a" and trashed=false) or (title contains "b" and trashed=false)`,
+		},
+		{
+			query: "title:a OR is:trashed",
+			want:  `(title contains "a" and trashed=false) or (trashed=true)`,
+		},
+		{query: "OR", fails: true},
+		{query: "a OR", fails: true},
+		{query: "OR a", fails: true},
+		{query: "a OR OR b", fails: true},
+		{query: "-is:trashed", fails: true},
+		{query: "is:bogus", fails: true},
+		{query: "bogus:a", fails: true},
+		{query: "title:", fails: true},
+		{query: "modified>someday", fails: true},
+		{query: `"a`, fails: true},
+	}
+
+	for _, tc := range cases {
+		sq, err := parseSearchQuery(tc.query)
+		if tc.fails {
+			if err == nil {
+				t.Errorf("parseSearchQuery(%q) = %q, expected an error", tc.query, sq.expr())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSearchQuery(%q): unexpected error %v", tc.query, err)
+			continue
+		}
+		if got := sq.expr(); got != tc.want {
+			t.Errorf("parseSearchQuery(%q).expr() = %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestSearchQueryMatches(t *testing.T) {
+	trashed := &drive.File{Title: "a.txt", Labels: &drive.FileLabels{Trashed: true}}
+	untrashed := &drive.File{Title: "a.txt", Labels: &drive.FileLabels{}}
+	otherTrashed := &drive.File{Title: "b.txt", Labels: &drive.FileLabels{Trashed: true}}
+	other := &drive.File{Title: "b.txt", Labels: &drive.FileLabels{}}
+
+	cases := []struct {
+		query string
+		f     *drive.File
+		want  bool
+	}{
+		{query: "", f: untrashed, want: true},
+		{query: "", f: trashed, want: false},
+		{query: "title:a", f: untrashed, want: true},
+		{query: "title:a", f: trashed, want: false},
+		{query: "title:a", f: other, want: false},
+		{query: "-title:a", f: other, want: true},
+		{query: "is:trashed", f: trashed, want: true},
+		{query: "is:trashed", f: untrashed, want: false},
+		{query: "title:a is:trashed", f: trashed, want: true},
+		{query: "title:a is:trashed", f: otherTrashed, want: false},
+		{query: "title:a OR is:trashed", f: untrashed, want: true},
+		{query: "title:a OR is:trashed", f: otherTrashed, want: true},
+		{query: "title:a OR is:trashed", f: other, want: false},
+		{query: "title:a OR title:b", f: other, want: true},
+		{query: "title:a OR title:b", f: otherTrashed, want: false},
+	}
+
+	for _, tc := range cases {
+		sq, err := parseSearchQuery(tc.query)
+		if err != nil {
+			t.Errorf("parseSearchQuery(%q): unexpected error %v", tc.query, err)
+			continue
+		}
+		if got := sq.matches(tc.f); got != tc.want {
+			t.Errorf("%q matching %q trashed=%v = %v, want %v", tc.query, tc.f.Title, tc.f.Labels.Trashed, got, tc.want)
+		}
+	}
+}
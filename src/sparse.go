@@ -0,0 +1,193 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	SparseAdd    = "add"
+	SparseRemove = "remove"
+	SparseList   = "list"
+	SparseReset  = "reset"
+)
+
+// sparseProfile lists the remote paths that are included in and excluded
+// from the local copy. With no includes, everything but the excludes is.
+type sparseProfile struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+func readSparse(root string) (*sparseProfile, error) {
+	p := config.SparseAbsPath(root)
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	sp := &sparseProfile{}
+	if err = json.Unmarshal(data, sp); err != nil {
+		return nil, fmt.Errorf("%s is not a valid sparse profile: %v. Fix it or run `drive %s %s` to include everything again",
+			p, err, SparseKey, SparseReset)
+	}
+	return sp, nil
+}
+
+func writeSparse(root string, sp *sparseProfile) error {
+	data, err := json.MarshalIndent(sp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(config.SparseAbsPath(root), append(data, '\n'), 0600)
+}
+
+func underPath(p, parent string) bool {
+	return rootLike(parent) || p == parent || strings.HasPrefix(p, parent+"/")
+}
+
+// materialized reports whether a path is part of the local copy,
+// the most specific include or exclude containing it decides.
+func (sp *sparseProfile) materialized(p string) bool {
+	if sp == nil {
+		return true
+	}
+	longest, included := -1, len(sp.Include) < 1
+	for _, inc := range sp.Include {
+		if underPath(p, inc) && len(inc) > longest {
+			longest, included = len(inc), true
+		}
+	}
+	for _, exc := range sp.Exclude {
+		if underPath(p, exc) && len(exc) >= longest {
+			longest, included = len(exc), false
+		}
+	}
+	return included
+}
+
+// traversable reports whether a folder is materialized or has to
+// be walked through to reach an included path beneath it.
+func (sp *sparseProfile) traversable(p string) bool {
+	if sp.materialized(p) {
+		return true
+	}
+	for _, inc := range sp.Include {
+		if underPath(inc, p) && sp.materialized(inc) {
+			return true
+		}
+	}
+	return false
+}
+
+// sparseSkips reports whether resolution should leave a child alone.
+// Pull skips subtrees outside the profile without listing them and
+// push never deletes remote content outside the profile.
+func (g *Commands) sparseSkips(p string, l *dirList, isPush bool) bool {
+	inProfile := g.sparse.materialized(p)
+	if isPush {
+		return l.local == nil && !inProfile
+	}
+	if !g.sparse.traversable(p) {
+		return true
+	}
+	return !inProfile && (l.remote == nil || !l.IsDir())
+}
+
+func withoutPath(paths []string, p string) (rest []string) {
+	for _, other := range paths {
+		if other != p {
+			rest = append(rest, other)
+		}
+	}
+	return
+}
+
+// Sparse adds the sources to the included, or excluded, remote
+// paths of the profile, removes them from it, lists it or resets it.
+func (g *Commands) Sparse(action string, exclude bool) error {
+	root := g.context.AbsPathOf("")
+	if action == SparseReset {
+		// The profile is removed without being read as it may be corrupt
+		if err := os.Remove(config.SparseAbsPath(root)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		g.log.Logln("Everything is included, pull to materialize it.")
+		return nil
+	}
+
+	sp, err := readSparse(root)
+	if err != nil {
+		return err
+	}
+	if sp == nil {
+		sp = &sparseProfile{}
+	}
+
+	switch action {
+	case SparseList:
+		if len(sp.Include) < 1 && len(sp.Exclude) < 1 {
+			g.log.Logln("Everything is included.")
+			return nil
+		}
+		for _, p := range sp.Include {
+			g.log.Logf("include %s\n", p)
+		}
+		for _, p := range sp.Exclude {
+			g.log.Logf("exclude %s\n", p)
+		}
+		return nil
+	case SparseAdd, SparseRemove:
+	default:
+		return fmt.Errorf("sparse: unknown action '%s', expecting one of %s, %s, %s or %s",
+			action, SparseAdd, SparseRemove, SparseList, SparseReset)
+	}
+
+	if len(g.opts.Sources) < 1 {
+		return fmt.Errorf("sparse %s: expecting at least one path", action)
+	}
+
+	for _, p := range g.opts.Sources {
+		sp.Include = withoutPath(sp.Include, p)
+		sp.Exclude = withoutPath(sp.Exclude, p)
+		if action == SparseRemove {
+			continue
+		}
+		if exclude {
+			sp.Exclude = append(sp.Exclude, p)
+		} else {
+			sp.Include = append(sp.Include, p)
+		}
+	}
+
+	if err = writeSparse(root, sp); err != nil {
+		return err
+	}
+	if action == SparseAdd && !exclude {
+		g.log.Logf("Pull to materialize the newly included path(s)\n")
+	} else if action == SparseAdd {
+		g.log.Logf("Local copies of the newly excluded path(s) are left in place\n")
+	}
+	return nil
+}
@@ -29,6 +29,7 @@ type keyValue struct {
 }
 
 func (g *Commands) Stat() error {
+	g.rem.allowCachedLookups()
 	channelMap := make(map[int]chan *keyValue)
 	var wg sync.WaitGroup
 	wg.Add(len(g.opts.Sources))
@@ -37,7 +38,7 @@ func (g *Commands) Stat() error {
 			defer wgg.Done()
 			chMap := *chanMap
 
-			file, err := g.rem.FindByPath(p)
+			file, err := g.findByPath(p, false)
 			if err == nil {
 				chMap[id] = g.stat(p, file)
 				return
@@ -132,21 +133,40 @@ func (g *Commands) stat(relToRootPath string, file *File) chan *keyValue {
 			close(statChan)
 		}()
 
-		prettyFileStat(g.log.Logf, relToRootPath, file)
-		perms, permErr := g.rem.listPermissions(file.Id)
+		if g.records == nil {
+			prettyFileStat(g.log.Logf, relToRootPath, file)
+		}
+		// Offline, only the permissions that came along with the cached metadata are known
+		perms := file.Permissions
+		var permErr error
+		if !g.opts.Offline {
+			perms, permErr = g.rem.listPermissions(file.Id)
+		}
+		if g.records != nil {
+			if wErr := g.records.write(newFileRecord(relToRootPath, file, perms)); wErr != nil && permErr == nil {
+				permErr = wErr
+			}
+		}
 		if permErr != nil {
 			kv.value = permErr
 			return
 		}
 
-		for _, perm := range perms {
-			prettyPermission(g.log.Logf, perm)
+		if g.records == nil {
+			for _, perm := range perms {
+				prettyPermission(g.log.Logf, perm)
+			}
 		}
 		if !file.IsDir || !g.opts.Recursive {
 			return
 		}
 
-		remoteChildren := g.rem.FindByParentId(file.Id, g.opts.Hidden)
+		var remoteChildren chan *File
+		if g.opts.Offline {
+			remoteChildren = g.cachedChildren(file.Id, false, g.opts.Hidden)
+		} else {
+			remoteChildren = g.rem.FindByParentId(file.Id, g.opts.Hidden)
+		}
 		channelMap := make(map[int]chan *keyValue)
 		i := 0
 		for child := range remoteChildren {
@@ -0,0 +1,290 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	StatusLocalModified  = "local-modified"
+	StatusRemoteModified = "remote-modified"
+	StatusConflicted     = "conflicted"
+	StatusUntracked      = "untracked"
+	StatusRemoteAdded    = "remote-added"
+	StatusDeletedLocal   = "deleted-locally"
+	StatusDeletedRemote  = "deleted-remotely"
+)
+
+// statusOrder is the order in which groups are printed.
+var statusOrder = []string{
+	StatusConflicted,
+	StatusLocalModified,
+	StatusRemoteModified,
+	StatusUntracked,
+	StatusRemoteAdded,
+	StatusDeletedLocal,
+	StatusDeletedRemote,
+}
+
+var statusHeadings = map[string]string{
+	StatusConflicted:     "Modified both locally and remotely",
+	StatusLocalModified:  "Modified locally, to push",
+	StatusRemoteModified: "Modified remotely, to pull",
+	StatusUntracked:      "Untracked, only present locally",
+	StatusRemoteAdded:    "Only present remotely",
+	StatusDeletedLocal:   "Deleted locally",
+	StatusDeletedRemote:  "Deleted remotely",
+}
+
+// statusCodes are the two column codes of the short format,
+// the left column is the local side and the right the remote side.
+var statusCodes = map[string]string{
+	StatusConflicted:     "UU",
+	StatusLocalModified:  "M ",
+	StatusRemoteModified: " M",
+	StatusUntracked:      "??",
+	StatusRemoteAdded:    " A",
+	StatusDeletedLocal:   "D ",
+	StatusDeletedRemote:  " D",
+}
+
+type statusEntry struct {
+	Path     string `json:"path"`
+	State    string `json:"state"`
+	IsDir    bool   `json:"isDir"`
+	RemoteId string `json:"remoteId,omitempty"`
+
+	sides *sides
+}
+
+type byStatusPath []*statusEntry
+
+func (s byStatusPath) Len() int           { return len(s) }
+func (s byStatusPath) Less(i, j int) bool { return s[i].Path < s[j].Path }
+func (s byStatusPath) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// sides holds the local and remote files found at a path.
+type sides struct {
+	local  *File
+	remote *File
+	parent string
+}
+
+// resolveStatus runs both the push and pull change resolutions
+// over the sources and classifies every differing path.
+func (g *Commands) resolveStatus() ([]*statusEntry, error) {
+	found := map[string]*sides{}
+
+	collect := func(cl []*Change, isPush bool) {
+		for _, c := range cl {
+			local, remote := c.Dest, c.Src
+			if isPush {
+				local, remote = c.Src, c.Dest
+			}
+			found[c.Path] = &sides{local: local, remote: remote, parent: c.Parent}
+		}
+	}
+
+	spin := g.playabler()
+	spin.play()
+
+	// Push resolution descends into local only folders
+	// while pull resolution descends into remote only ones.
+	for _, isPush := range []bool{true, false} {
+		for _, relToRootPath := range g.opts.Sources {
+			fsPath := g.context.AbsPathOf(relToRootPath)
+			cl, err := g.changeListResolve(relToRootPath, fsPath, isPush)
+			if err != nil {
+				spin.stop()
+				return nil, err
+			}
+			collect(cl, isPush)
+		}
+	}
+
+	spin.stop()
+
+	var entries []*statusEntry
+	for p, s := range found {
+		entry, err := g.classify(p, s)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Sort(byStatusPath(entries))
+	return entries, nil
+}
+
+// Status reports how each path that differs between the local and
+// remote copies has diverged. Nothing is prompted for and nothing is modified.
+func (g *Commands) Status(short, asJSON bool) error {
+	entries, err := g.resolveStatus()
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		if entries == nil {
+			entries = []*statusEntry{}
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+
+	if len(entries) < 1 {
+		g.log.Logln("Everything is up-to-date.")
+		return nil
+	}
+
+	if short {
+		for _, entry := range entries {
+			g.log.Logln(entry)
+		}
+		return nil
+	}
+
+	grouped := map[string][]*statusEntry{}
+	for _, entry := range entries {
+		grouped[entry.State] = append(grouped[entry.State], entry)
+	}
+	for _, state := range statusOrder {
+		group := grouped[state]
+		if len(group) < 1 {
+			continue
+		}
+		g.log.Logf("%s (%d):\n", statusHeadings[state], len(group))
+		for _, entry := range group {
+			suffix := ""
+			if entry.IsDir {
+				suffix = "/"
+			}
+			g.log.Logf("\t%s%s\n", entry.Path, suffix)
+		}
+		g.log.Logln()
+	}
+	return nil
+}
+
+// classify decides which side a path diverged on by comparing both sides
+// against the index recorded at the last push or pull of the file.
+func (g *Commands) classify(p string, s *sides) (*statusEntry, error) {
+	local, remote := s.local, s.remote
+	entry := &statusEntry{Path: p, sides: s}
+	if local != nil {
+		entry.IsDir = local.IsDir
+	}
+	if remote != nil {
+		entry.RemoteId = remote.Id
+		entry.IsDir = entry.IsDir || remote.IsDir
+	}
+
+	switch {
+	case local == nil && remote == nil:
+		return nil, nil
+	case remote == nil:
+		trashed, err := g.rem.FindByPathTrashed(p)
+		if err != nil && err != ErrPathNotExists {
+			return nil, err
+		}
+		switch {
+		case trashed == nil:
+			entry.State = StatusUntracked
+		case local.IsDir:
+			entry.State = StatusDeletedRemote
+		default:
+			// A local copy edited after the last sync must not be
+			// deleted because the remote was trashed meanwhile.
+			index := g.deserializeIndex(trashed.Id)
+			if index == nil || g.changedSinceIndex(local, index) {
+				entry.State = StatusConflicted
+			} else {
+				entry.State = StatusDeletedRemote
+			}
+		}
+		return entry, nil
+	case local == nil:
+		index := g.deserializeIndex(remote.Id)
+		switch {
+		case index == nil:
+			entry.State = StatusRemoteAdded
+		case !remote.IsDir && remote.Etag != index.Etag && g.changedSinceIndex(remote, index):
+			entry.State = StatusConflicted
+		default:
+			entry.State = StatusDeletedLocal
+		}
+		return entry, nil
+	}
+
+	if local.IsDir && remote.IsDir {
+		return nil, nil
+	}
+
+	index := g.deserializeIndex(remote.Id)
+	if index == nil || local.IsDir != remote.IsDir {
+		entry.State = newerSide(local, remote)
+		return entry, nil
+	}
+
+	localChanged := g.changedSinceIndex(local, index)
+	remoteChanged := remote.Etag != index.Etag && g.changedSinceIndex(remote, index)
+
+	switch {
+	case localChanged && remoteChanged:
+		entry.State = StatusConflicted
+	case localChanged:
+		entry.State = StatusLocalModified
+	case remoteChanged:
+		entry.State = StatusRemoteModified
+	default:
+		entry.State = newerSide(local, remote)
+	}
+	return entry, nil
+}
+
+func (g *Commands) changedSinceIndex(f *File, index *config.Index) bool {
+	if f.ModTime.Unix() == index.ModTime {
+		return false
+	}
+	if g.opts.IgnoreChecksum || index.Md5Checksum == "" {
+		return true
+	}
+	return md5Checksum(f) != index.Md5Checksum
+}
+
+// newerSide is the fallback when there is no record of
+// the last sync, the most recently modified side wins.
+func newerSide(local, remote *File) string {
+	if local.ModTime.After(remote.ModTime) {
+		return StatusLocalModified
+	}
+	return StatusRemoteModified
+}
+
+func (s *statusEntry) String() string {
+	return fmt.Sprintf("%s %s", statusCodes[s.State], s.Path)
+}
@@ -0,0 +1,148 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+)
+
+// Sync resolves local and remote once, pushes the paths that only changed
+// locally and pulls the paths that only changed remotely. Paths changed on
+// both sides are conflicts, unless IgnoreConflict is set in which case the
+// most recently modified side wins.
+func (g *Commands) Sync() (err error) {
+	g.log.Logln("Resolving...")
+
+	entries, err := g.resolveStatus()
+	if err != nil {
+		return err
+	}
+
+	var cl, conflicts []*Change
+	for _, entry := range entries {
+		s := entry.sides
+		state := entry.State
+		if state == StatusConflicted {
+			if !g.opts.IgnoreConflict {
+				conflicts = append(conflicts, &Change{Path: entry.Path, Src: s.local, Dest: s.remote, Parent: s.parent})
+				continue
+			}
+			state = conflictWinner(s.local, s.remote)
+		}
+
+		switch state {
+		case StatusLocalModified, StatusUntracked, StatusDeletedLocal:
+			cl = append(cl, g.syncChange(entry.Path, s.parent, s.local, s.remote, false))
+		case StatusRemoteModified, StatusRemoteAdded, StatusDeletedRemote:
+			cl = append(cl, g.syncChange(entry.Path, s.parent, s.remote, s.local, true))
+		}
+	}
+
+	if conflictsPersist(conflicts) {
+		warnConflictsPersist(g.log, conflicts)
+		return fmt.Errorf("conflicts have prevented a sync operation")
+	}
+
+	if len(cl) < 1 {
+		g.log.Logln("Everything is up-to-date.")
+		return nil
+	}
+
+	if g.opts.canPrompt() {
+		pushes, pulls := splitSyncChanges(cl)
+		if len(pushes) >= 1 {
+			g.log.Logln("To push:")
+			previewChanges(g.log, pushes, true, opChangeCount(pushes))
+		}
+		if len(pulls) >= 1 {
+			g.log.Logln("To pull:")
+			previewChanges(g.log, pulls, true, opChangeCount(pulls))
+		}
+		if !promptForChanges() {
+			return nil
+		}
+	}
+
+	unlock, err := g.lockContext()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	g.beginHistory(SyncKey)
+	defer g.commitHistory()
+
+	return g.playSyncChanges(cl)
+}
+
+// playSyncChanges applies both directions of a sync as a single play
+// sharing one progress bar. Pushes go first so that pulls see the
+// remote folders that they create.
+func (g *Commands) playSyncChanges(cl []*Change) error {
+	totalSize := int64(0)
+	for _, counter := range opChangeCount(cl) {
+		totalSize += counter.src
+	}
+
+	progress := g.startProgress(totalSize)
+	pushes, pulls := splitSyncChanges(cl)
+	conflicts := g.pushChanges(pushes, progress)
+	g.pullChanges(pulls, g.opts.Exports, progress)
+	g.finishProgress(progress)
+
+	if conflictsPersist(conflicts) {
+		warnRemoteModified(g.log, conflicts)
+		return fmt.Errorf("%d remote file(s) changed after resolving and were not overwritten", len(conflicts))
+	}
+	return nil
+}
+
+// conflictWinner settles a conflict for IgnoreConflict. When one side was
+// deleted and the other edited, the edit wins and the deletion is undone.
+func conflictWinner(local, remote *File) string {
+	switch {
+	case remote == nil:
+		return StatusLocalModified
+	case local == nil:
+		return StatusRemoteModified
+	}
+	return newerSide(local, remote)
+}
+
+// syncChange builds a change whose direction was already settled by
+// classification, so it must not be re-flagged as a conflict.
+func (g *Commands) syncChange(p, parent string, src, dest *File, pull bool) *Change {
+	return &Change{
+		Path:           p,
+		Parent:         parent,
+		Src:            src,
+		Dest:           dest,
+		IgnoreChecksum: g.opts.IgnoreChecksum,
+		IgnoreConflict: true,
+		pull:           pull,
+	}
+}
+
+// splitSyncChanges separates the changes of a sync by direction.
+func splitSyncChanges(cl []*Change) (pushes, pulls []*Change) {
+	for _, c := range cl {
+		if c.pull {
+			pulls = append(pulls, c)
+		} else {
+			pushes = append(pushes, c)
+		}
+	}
+	return
+}
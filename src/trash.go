@@ -120,6 +120,10 @@ func (g *Commands) trashByMatch(inTrash, permanent bool) error {
 	}
 
 	toTrash := !inTrash
+	if g.opts.DryRun {
+		return g.planTrashChangeList(cl, toTrash, permanent)
+	}
+
 	ok, _ := printChangeList(g.log, cl, !g.opts.canPrompt(), false)
 	if !ok {
 		return nil
@@ -151,6 +155,10 @@ func (g *Commands) reduceForTrash(args []string, toTrash, permanent bool) error
 		}
 	}
 
+	if g.opts.DryRun {
+		return g.planTrashChangeList(cl, toTrash, permanent)
+	}
+
 	ok, _ := printChangeList(g.log, cl, !g.opts.canPrompt(), false)
 	if !ok {
 		return nil
@@ -164,6 +172,22 @@ func (g *Commands) reduceForTrash(args []string, toTrash, permanent bool) error
 }
 
 func (g *Commands) playTrashChangeList(cl []*Change, toTrash, permanent bool) (err error) {
+	unlock, err := g.lockContext()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Permanent deletions cannot be reversed so they are not recorded
+	if !permanent {
+		command := UntrashKey
+		if toTrash {
+			command = TrashKey
+		}
+		g.beginHistory(command)
+		defer g.commitHistory()
+	}
+
 	trashSize, unTrashSize := reduceToSize(cl, SelectDest|SelectSrc)
 	g.taskStart(trashSize + unTrashSize)
 
@@ -177,17 +201,43 @@ func (g *Commands) playTrashChangeList(cl []*Change, toTrash, permanent bool) (e
 		}
 	}
 
+	var conflicts []*Change
 	for _, c := range cl {
 		if c.Op() == OpNone {
 			continue
 		}
 
 		cErr := fn(c)
-		if cErr != nil {
+		if cErr == ErrRemoteModified {
+			conflicts = append(conflicts, c)
+		} else if cErr != nil {
 			g.log.LogErrln(cErr)
 		}
 	}
 
 	g.taskFinish()
+
+	if conflictsPersist(conflicts) {
+		warnRemoteModified(g.log, conflicts)
+		return fmt.Errorf("conflicts have prevented a trash operation")
+	}
 	return err
 }
+
+func (g *Commands) planTrashChangeList(cl []*Change, toTrash, permanent bool) error {
+	op := PlanOpUntrash
+	if permanent {
+		op = PlanOpDelete
+	} else if toTrash {
+		op = PlanOpTrash
+	}
+
+	g.beginPlan(op)
+	for _, c := range cl {
+		if c.Op() == OpNone {
+			continue
+		}
+		g.planAdd(newPlanEntry(op, c))
+	}
+	return g.emitPlan()
+}
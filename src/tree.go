@@ -0,0 +1,110 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	treeBranch     = "├── "
+	treeLastBranch = "└── "
+	treeIndent     = "│   "
+	treeLastIndent = "    "
+)
+
+type treeCounts struct {
+	dirs  int
+	files int
+}
+
+// treeAnnotation is the size and id of a file, as requested by the mask.
+func treeAnnotation(f *File, mask int) (annotation string) {
+	if (mask&Sizes) != 0 && !f.IsDir {
+		annotation += fmt.Sprintf(" [%s]", prettyBytes(f.Size))
+	}
+	if (mask & Ids) != 0 {
+		annotation += fmt.Sprintf(" (%s)", f.Id)
+	}
+	return
+}
+
+// drawTree prints the children of a folder beneath it, each
+// level prefixed by the branches of the levels above.
+func (g *Commands) drawTree(folder *File, prefix string, depth int, counts *treeCounts, spin *playable) {
+	// A depth of < 0 means traverse as deep as you can
+	if depth == 0 {
+		return
+	}
+
+	mask := g.opts.TypeMask
+	var children []*File
+	for child := range g.listChildren(folder, mask, false, spin) {
+		if child == nil || isHidden(child.Name, g.opts.Hidden) {
+			continue
+		}
+		// Covered by buildExpression when online
+		if g.opts.Offline && (mask&Folder) != 0 && !child.IsDir {
+			continue
+		}
+		if !g.opts.Filter.Allows(child) {
+			continue
+		}
+		children = append(children, child)
+	}
+	sort.Sort(&byListOrder{files: children, key: g.opts.Sort, reverse: g.opts.Reverse, foldersFirst: g.opts.FoldersFirst})
+
+	for i, child := range children {
+		branch, indent := treeBranch, treeIndent
+		if i == len(children)-1 {
+			branch, indent = treeLastBranch, treeLastIndent
+		}
+		g.log.Logf("%s%s%s%s\n", prefix, branch, child.Name, treeAnnotation(child, mask))
+		if !child.IsDir {
+			counts.files += 1
+			continue
+		}
+		counts.dirs += 1
+		g.drawTree(child, prefix+indent, depth-1, counts, spin)
+	}
+}
+
+// Tree draws the remote hierarchy beneath each source down to Depth.
+func (g *Commands) Tree() error {
+	g.rem.allowCachedLookups()
+	counts := &treeCounts{}
+	spin := g.playabler()
+	spin.play()
+	for _, relPath := range g.opts.Sources {
+		f, err := g.findByPath(relPath, false)
+		if err != nil {
+			g.log.LogErrf("%s: %v\n", relPath, err)
+			continue
+		}
+		g.log.Logf("%s%s\n", relPath, treeAnnotation(f, g.opts.TypeMask))
+		if f.IsDir {
+			g.drawTree(f, "", g.opts.Depth, counts, spin)
+		}
+	}
+	spin.stop()
+
+	if (g.opts.TypeMask & Folder) != 0 {
+		g.log.Logf("\n%d folder(s)\n", counts.dirs)
+	} else {
+		g.log.Logf("\n%d folder(s), %d file(s)\n", counts.dirs, counts.files)
+	}
+	return nil
+}
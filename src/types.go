@@ -33,6 +33,7 @@ const (
 	OpDelete
 	OpMod
 	OpModConflict
+	OpRename
 )
 
 type CrudValue int
@@ -70,6 +71,7 @@ var opPrecedence = map[Operation]int{
 	OpAdd:         2,
 	OpMod:         3,
 	OpModConflict: 4,
+	OpRename:      5,
 }
 
 type File struct {
@@ -94,6 +96,8 @@ type File struct {
 	CacheChecksum bool
 	// Monotonically increasing version number for the file
 	Version int64
+	// HeadRevisionId is the id of the file's latest revision
+	HeadRevisionId string
 	// The onwers of this file.
 	OwnerNames []string
 	// Permissions contains the overall permissions for this file
@@ -120,6 +124,7 @@ func NewRemoteFile(f *drive.File) *File {
 		Shared:         f.Shared,
 		UserPermission: f.UserPermission,
 		Version:        f.Version,
+		HeadRevisionId: f.HeadRevisionId,
 		OwnerNames:     f.OwnerNames,
 		Permissions:    f.Permissions,
 	}
@@ -142,6 +147,7 @@ func DupFile(f *File) *File {
 		Shared:         f.Shared,
 		UserPermission: f.UserPermission,
 		Version:        f.Version,
+		HeadRevisionId: f.HeadRevisionId,
 		OwnerNames:     f.OwnerNames,
 		Permissions:    f.Permissions,
 	}
@@ -180,6 +186,10 @@ type Change struct {
 	NoClobber      bool
 	IgnoreConflict bool
 	IgnoreChecksum bool
+	// RenameTo is the title that -fix-clashes renames the remote Dest to
+	RenameTo string
+	// pull marks the changes of a sync that are applied locally
+	pull bool
 }
 
 type ByPrecedence []*Change
@@ -218,6 +228,8 @@ func (op *Operation) description() (symbol, info string) {
 		return "\033[33mM\033[0m", "Modification"
 	case OpModConflict:
 		return "\033[35mX\033[0m", "Clashing modification"
+	case OpRename:
+		return "\033[36mR\033[0m", "Rename"
 	default:
 		return "", ""
 	}
@@ -317,7 +329,7 @@ func (c *Change) crudValue() CrudValue {
 	if op == OpAdd {
 		return Create
 	}
-	if op == OpMod || op == OpModConflict {
+	if op == OpMod || op == OpModConflict || op == OpRename {
 		return Update
 	}
 	if op == OpDelete {
@@ -327,6 +339,9 @@ func (c *Change) crudValue() CrudValue {
 }
 
 func (c *Change) op() Operation {
+	if c.RenameTo != "" {
+		return OpRename
+	}
 	if c.Src == nil && c.Dest == nil {
 		return OpNone
 	}
@@ -360,6 +375,9 @@ func (c *Change) op() Operation {
 
 func (c *Change) Op() Operation {
 	op := c.op()
+	if op == OpRename {
+		return op
+	}
 	if c.Force {
 		if op == OpModConflict {
 			return OpMod
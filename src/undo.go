@@ -0,0 +1,374 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	InverseTrash       = "trash"
+	InverseUntrash     = "untrash"
+	InverseMove        = "move"
+	InverseRename      = "rename"
+	InverseRevert      = "revert"
+	InverseUnstash     = "unstash"
+	InverseRemoveLocal = "remove-local"
+)
+
+// inverseOp describes how to reverse a single mutation.
+type inverseOp struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+	Id   string `json:"id,omitempty"`
+	// FromParentId and ToParentId are the parents that a move
+	// inverse removes the file from and re-inserts it into.
+	FromParentId string `json:"from,omitempty"`
+	ToParentId   string `json:"to,omitempty"`
+	Title        string `json:"title,omitempty"`
+	RevisionId   string `json:"rev,omitempty"`
+	StashPath    string `json:"stash,omitempty"`
+}
+
+// historyEntry groups the inverses of all the mutations made by one command.
+// Entries are only ever appended, undoing an entry appends another entry
+// that refers to it through Undoes.
+type historyEntry struct {
+	Id      int64        `json:"id"`
+	Command string       `json:"cmd"`
+	Time    int64        `json:"time"`
+	Undoes  int64        `json:"undoes,omitempty"`
+	Ops     []*inverseOp `json:"ops,omitempty"`
+}
+
+// Stashed local content older than this is pruned, after which
+// the pulls that displaced it can no longer restore it.
+const maxStashAge = 30 * 24 * time.Hour
+
+type historian struct {
+	sync.Mutex
+	entry *historyEntry
+}
+
+func (op *inverseOp) String() string {
+	switch op.Kind {
+	case InverseTrash:
+		return fmt.Sprintf("trash %s", op.Path)
+	case InverseUntrash:
+		return fmt.Sprintf("untrash %s", op.Path)
+	case InverseMove:
+		return fmt.Sprintf("move %s back to its previous folder", op.Path)
+	case InverseRename:
+		return fmt.Sprintf("rename %s back to '%s'", op.Path, op.Title)
+	case InverseRevert:
+		return fmt.Sprintf("revert %s to revision %s", op.Path, op.RevisionId)
+	case InverseUnstash:
+		return fmt.Sprintf("restore local %s", op.Path)
+	case InverseRemoveLocal:
+		return fmt.Sprintf("remove local %s", op.Path)
+	}
+	return fmt.Sprintf("%s %s", op.Kind, op.Path)
+}
+
+func (g *Commands) beginHistory(command string) {
+	now := time.Now()
+	g.history = &historian{
+		entry: &historyEntry{
+			Id:      now.UnixNano(),
+			Command: command,
+			Time:    now.Unix(),
+		},
+	}
+}
+
+func (g *Commands) recordInverse(op *inverseOp) {
+	if g.history == nil || op == nil {
+		return
+	}
+	g.history.Lock()
+	g.history.entry.Ops = append(g.history.entry.Ops, op)
+	g.history.Unlock()
+}
+
+func (g *Commands) commitHistory() {
+	if g.history == nil {
+		return
+	}
+	entry := g.history.entry
+	g.history = nil
+
+	if len(entry.Ops) < 1 && entry.Undoes == 0 {
+		return
+	}
+	root := g.context.AbsPathOf("")
+	if err := appendHistory(root, entry); err != nil {
+		g.log.LogErrf("history: %v\n", err)
+	}
+	if err := pruneStash(root, maxStashAge); err != nil {
+		g.log.LogErrf("stash: %v\n", err)
+	}
+}
+
+// pruneStash removes the local content stashed by commands older than
+// maxAge, their stash folders being named after the history entry ids.
+func pruneStash(root string, maxAge time.Duration) error {
+	stashDir := config.StashAbsPath(root, "")
+	infos, err := ioutil.ReadDir(stashDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return err
+	}
+
+	cutOff := time.Now().Add(-maxAge).UnixNano()
+	for _, info := range infos {
+		id, pErr := strconv.ParseInt(info.Name(), 10, 64)
+		if pErr != nil || id >= cutOff {
+			continue
+		}
+		if err = os.RemoveAll(filepath.Join(stashDir, info.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendHistory(root string, entry *historyEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(config.HistoryAbsPath(root), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func readHistory(root string) (entries []*historyEntry, err error) {
+	f, err := os.Open(config.HistoryAbsPath(root))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		entry := &historyEntry{}
+		if err = dec.Decode(entry); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// undoable returns at most n of the most recent entries that
+// have neither been undone nor are themselves undo records.
+func undoable(entries []*historyEntry, n int) (pending []*historyEntry) {
+	undone := map[int64]bool{}
+	for _, entry := range entries {
+		if entry.Undoes != 0 {
+			undone[entry.Undoes] = true
+		}
+	}
+
+	for i := len(entries) - 1; i >= 0 && len(pending) < n; i-- {
+		entry := entries[i]
+		if entry.Undoes != 0 || undone[entry.Id] || len(entry.Ops) < 1 {
+			continue
+		}
+		pending = append(pending, entry)
+	}
+	return
+}
+
+// stash moves local content that is about to be overwritten or
+// removed out of the way, recording how to bring it back.
+func (g *Commands) stash(relToRoot string) error {
+	if g.history == nil {
+		return nil
+	}
+	absPath := g.context.AbsPathOf(relToRoot)
+	if _, err := os.Lstat(absPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	stashPath := config.StashAbsPath(g.context.AbsPathOf(""),
+		filepath.Join(fmt.Sprintf("%d", g.history.entry.Id), relToRoot))
+	if err := os.MkdirAll(filepath.Dir(stashPath), os.ModeDir|0755); err != nil {
+		return err
+	}
+	if err := os.Rename(absPath, stashPath); err != nil {
+		return err
+	}
+
+	g.recordInverse(&inverseOp{
+		Kind:      InverseUnstash,
+		Path:      relToRoot,
+		StashPath: stashPath,
+	})
+	return nil
+}
+
+func (g *Commands) Undo(n int) error {
+	if n < 1 {
+		n = 1
+	}
+
+	root := g.context.AbsPathOf("")
+	entries, err := readHistory(root)
+	if err != nil {
+		return err
+	}
+
+	pending := undoable(entries, n)
+	if len(pending) < 1 {
+		g.log.Logln("Nothing to undo.")
+		return nil
+	}
+
+	for _, entry := range pending {
+		g.log.Logf("%s at %v\n", entry.Command, time.Unix(entry.Time, 0))
+		for i := len(entry.Ops) - 1; i >= 0; i-- {
+			g.log.Logf("  %s\n", entry.Ops[i])
+		}
+	}
+
+	if g.opts.canPrompt() && !promptForChanges("Undo these changes? [Y/n]:") {
+		return nil
+	}
+
+	unlock, lErr := g.lockContext()
+	if lErr != nil {
+		return lErr
+	}
+	defer unlock()
+
+	// Another undo or the daemon may have changed the history while the
+	// prompt was up, only the entries still pending under the lock are undone.
+	if entries, err = readHistory(root); err != nil {
+		return err
+	}
+	if !sameEntries(pending, undoable(entries, n)) {
+		return fmt.Errorf("undo: the history changed meanwhile, run undo again to review it")
+	}
+
+	var failures []string
+	for _, entry := range pending {
+		var left []*inverseOp
+		// Inverses are replayed last recorded first.
+		for i := len(entry.Ops) - 1; i >= 0; i-- {
+			op := entry.Ops[i]
+			if opErr := g.playInverse(op); opErr != nil {
+				g.log.LogErrf("undo: %s: %v\n", op, opErr)
+				left = append([]*inverseOp{op}, left...)
+			}
+		}
+
+		g.beginHistory(UndoKey)
+		g.history.entry.Undoes = entry.Id
+		g.commitHistory()
+
+		if len(left) < 1 {
+			continue
+		}
+		failures = append(failures, fmt.Sprintf("%d operation(s) of '%s'", len(left), entry.Command))
+
+		// The operations that failed are recorded anew so
+		// that undo can retry them without replaying the rest.
+		g.beginHistory(entry.Command)
+		g.history.entry.Ops = left
+		g.commitHistory()
+	}
+
+	if len(failures) >= 1 {
+		return fmt.Errorf("undo: %s could not be reversed, run undo again to retry them", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+func sameEntries(a, b []*historyEntry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Id != b[i].Id {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Commands) playInverse(op *inverseOp) error {
+	switch op.Kind {
+	case InverseTrash:
+		return g.rem.Trash(op.Id)
+	case InverseUntrash:
+		return g.rem.Untrash(op.Id)
+	case InverseMove:
+		if err := g.rem.insertParent(op.Id, op.ToParentId); err != nil {
+			return err
+		}
+		return g.rem.removeParent(op.Id, op.FromParentId)
+	case InverseRename:
+		_, err := g.rem.rename(op.Id, op.Title, "")
+		return err
+	case InverseRevert:
+		_, err := g.rem.restoreRevision(op.Id, op.RevisionId)
+		return err
+	case InverseUnstash:
+		if _, err := os.Lstat(op.StashPath); err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("stashed copy was pruned")
+			}
+			return err
+		}
+		absPath := g.context.AbsPathOf(op.Path)
+		if err := os.RemoveAll(absPath); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(absPath), os.ModeDir|0755); err != nil {
+			return err
+		}
+		return os.Rename(op.StashPath, absPath)
+	case InverseRemoveLocal:
+		return os.RemoveAll(g.context.AbsPathOf(op.Path))
+	}
+	return fmt.Errorf("unknown inverse operation '%s'", op.Kind)
+}
@@ -0,0 +1,198 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/log"
+)
+
+// watchDebounce is how long the filesystem has to stay
+// quiet after an event before the affected paths are pushed.
+const watchDebounce = 2 * time.Second
+
+// fsWatcher delivers the absolute paths of filesystem events.
+type fsWatcher interface {
+	Events() <-chan string
+	Errors() <-chan error
+	Close() error
+}
+
+// Watch pushes local changes under the sources as they happen. Bursts of
+// events are debounced and only the affected paths are resolved and pushed.
+// Progress and errors are written to the watch log in the .gd directory.
+func (g *Commands) Watch() error {
+	root := g.context.AbsPathOf("")
+	logPath := config.WatchLogAbsPath(root)
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var roots []string
+	for _, relToRoot := range g.opts.Sources {
+		roots = append(roots, g.context.AbsPathOf(relToRoot))
+	}
+
+	w, err := newFsWatcher(roots, func(absPath string) bool {
+		return g.ignoredPath(g.watchRelToRoot(absPath), true)
+	})
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	g.log.Logf("Watching %s, logging to %s\n", strings.Join(g.opts.Sources, ", "), logPath)
+	g.logFile = f
+	g.log = log.New(os.Stdin, f, f)
+	g.log.Logf("%v watching %s\n", time.Now().Round(time.Second), strings.Join(g.opts.Sources, ", "))
+
+	pending := map[string]bool{}
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+
+	for {
+		select {
+		case absPath, ok := <-w.Events():
+			if !ok {
+				return nil
+			}
+			relToRoot := g.watchRelToRoot(absPath)
+			if filepath.Base(absPath) == DriveIgnoreSuffix {
+				g.opts.Ignorer.forget(filepath.Dir(relToRoot))
+			}
+			if g.ignoredPath(relToRoot, true) {
+				continue
+			}
+			pending[relToRoot] = true
+			timer.Reset(watchDebounce)
+		case wErr := <-w.Errors():
+			g.log.LogErrf("watch: %v\n", wErr)
+		case <-timer.C:
+			if !g.pushWatched(pending) {
+				// Another drive process holds the context, retry later
+				timer.Reset(watchDebounce)
+				continue
+			}
+			pending = map[string]bool{}
+		}
+	}
+}
+
+func (g *Commands) watchRelToRoot(absPath string) string {
+	rel, err := filepath.Rel(g.context.AbsPathOf(""), absPath)
+	if err != nil || rel == "." {
+		return "/"
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// ignoredPath reports whether changes to a path should be dropped,
+// following the same rules as push: .gd, hidden paths and .driveignore.
+func (g *Commands) ignoredPath(relToRoot string, isPush bool) bool {
+	for _, segment := range strings.Split(relToRoot, "/") {
+		if segment == "" {
+			continue
+		}
+		if segment == config.GDDirSuffix || isHidden(segment, g.opts.Hidden) {
+			return true
+		}
+	}
+	info, _ := os.Stat(g.context.AbsPathOf(relToRoot))
+	return g.opts.Ignorer.Match(relToRoot, info != nil && info.IsDir(), isPush)
+}
+
+// collapsePaths drops the paths that are covered by an ancestor also present.
+func collapsePaths(paths map[string]bool) (collapsed []string) {
+	var sorted []string
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	for _, p := range sorted {
+		n := len(collapsed)
+		if n >= 1 {
+			last := collapsed[n-1]
+			if last == "/" || strings.HasPrefix(p, last+"/") {
+				continue
+			}
+		}
+		collapsed = append(collapsed, p)
+	}
+	return
+}
+
+// pushWatched reports false if the paths could not be
+// pushed because the context is locked by another process.
+func (g *Commands) pushWatched(paths map[string]bool) bool {
+	unlock, err := g.lockContext()
+	if err != nil {
+		g.log.LogErrf("watch: %v\n", err)
+		return err != ErrContextLocked
+	}
+	defer unlock()
+
+	var cl []*Change
+	for _, relToRoot := range collapsePaths(paths) {
+		r, err := g.rem.FindByPath(relToRoot)
+		if err != nil && err != ErrPathNotExists {
+			g.log.LogErrf("%s: %v\n", relToRoot, err)
+			continue
+		}
+		fsPath := g.context.AbsPathOf(relToRoot)
+		l, err := g.resolveToLocalFile(relToRoot, fsPath, true)
+		if err != nil {
+			g.log.LogErrf("%s: %v\n", relToRoot, err)
+			continue
+		}
+		if l == nil && r == nil {
+			// Created and removed within the same burst
+			continue
+		}
+		ccl, err := g.doChangeListRecv(relToRoot, fsPath, l, r, true)
+		if err != nil {
+			g.log.LogErrf("%s: %v\n", relToRoot, err)
+			continue
+		}
+		cl = append(cl, ccl...)
+	}
+
+	nonConflictsPtr, conflictsPtr := g.resolveConflicts(cl, true)
+	if conflictsPtr != nil {
+		warnConflictsPersist(g.log, *conflictsPtr)
+	}
+	if nonConflictsPtr == nil || len(*nonConflictsPtr) < 1 {
+		return true
+	}
+	nonConflicts := *nonConflictsPtr
+
+	g.log.Logf("%v pushing %d change(s)\n", time.Now().Round(time.Second), len(nonConflicts))
+	previewChanges(g.log, nonConflicts, false, nil)
+
+	g.beginHistory(PushKey)
+	if err := g.playPushChanges(nonConflicts, nil); err != nil {
+		g.log.LogErrf("push: %v\n", err)
+	}
+	g.commitHistory()
+	return true
+}
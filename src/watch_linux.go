@@ -0,0 +1,198 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build linux
+
+package drive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY |
+	syscall.IN_ATTRIB | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+type inotifyWatcher struct {
+	fd int
+	// skip reports whether a path should neither be watched nor reported
+	skip func(absPath string) bool
+	// roots are the watched paths, mapped to whether they are folders
+	roots map[string]bool
+
+	mu   sync.Mutex
+	dirs map[int]string
+
+	events chan string
+	errors chan error
+}
+
+func newFsWatcher(roots []string, skip func(absPath string) bool) (fsWatcher, error) {
+	fd, err := syscall.InotifyInit()
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init", err)
+	}
+
+	w := &inotifyWatcher{
+		fd:     fd,
+		skip:   skip,
+		roots:  map[string]bool{},
+		dirs:   map[int]string{},
+		events: make(chan string),
+		errors: make(chan error),
+	}
+
+	for _, root := range roots {
+		if err = w.addRoot(root); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+
+	go w.readEvents()
+	return w, nil
+}
+
+func (w *inotifyWatcher) Events() <-chan string {
+	return w.events
+}
+
+func (w *inotifyWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *inotifyWatcher) Close() error {
+	return syscall.Close(w.fd)
+}
+
+// addRoot watches a folder and every folder beneath it. Watching a single
+// file watches only its parent folder, whose other entries are filtered out.
+func (w *inotifyWatcher) addRoot(root string) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	w.roots[root] = info.IsDir()
+	if !info.IsDir() {
+		return w.addWatch(filepath.Dir(root))
+	}
+	return w.addRecursive(root)
+}
+
+// watched reports whether p is a root or lies beneath a folder root.
+func (w *inotifyWatcher) watched(p string) bool {
+	for root, isDir := range w.roots {
+		if p == root || (isDir && strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *inotifyWatcher) addWatch(dir string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+	w.mu.Lock()
+	w.dirs[wd] = dir
+	w.mu.Unlock()
+	return nil
+}
+
+// addRecursive watches dir and every folder beneath
+// it since inotify watches are not recursive.
+func (w *inotifyWatcher) addRecursive(dir string) error {
+	return filepath.Walk(dir, func(p string, fi os.FileInfo, walkErr error) error {
+		if walkErr != nil || !fi.IsDir() {
+			return nil
+		}
+		if p != dir && w.skip(p) {
+			return filepath.SkipDir
+		}
+		return w.addWatch(p)
+	})
+}
+
+// rescan re-watches the folder roots and reports every root as changed,
+// the events that the kernel dropped once its queue overflowed being lost.
+func (w *inotifyWatcher) rescan() {
+	for root, isDir := range w.roots {
+		if isDir {
+			if err := w.addRecursive(root); err != nil {
+				w.errors <- err
+			}
+		}
+		w.events <- root
+	}
+}
+
+func (w *inotifyWatcher) readEvents() {
+	defer close(w.events)
+
+	var buf [syscall.SizeofInotifyEvent * 4096]byte
+	for {
+		n, err := syscall.Read(w.fd, buf[:])
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			return
+		}
+		if n < syscall.SizeofInotifyEvent {
+			continue
+		}
+
+		offset := 0
+		for offset <= n-syscall.SizeofInotifyEvent {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(raw.Len)]), "\x00")
+			offset = nameStart + int(raw.Len)
+
+			if raw.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				w.rescan()
+				continue
+			}
+
+			w.mu.Lock()
+			dir, known := w.dirs[int(raw.Wd)]
+			if raw.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.dirs, int(raw.Wd))
+			}
+			w.mu.Unlock()
+
+			if !known || name == "" {
+				continue
+			}
+
+			p := filepath.Join(dir, name)
+			if !w.watched(p) || w.skip(p) {
+				continue
+			}
+
+			created := raw.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0
+			if created && raw.Mask&syscall.IN_ISDIR != 0 {
+				if addErr := w.addRecursive(p); addErr != nil {
+					w.errors <- addErr
+				}
+			}
+			w.events <- p
+		}
+	}
+}